@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// audioSettings are the user's choices for the audio track. Zero values mean
+// "decide for me": the container's default codec, an automatic bitrate and
+// the source's channels and sample rate.
+type audioSettings struct {
+	codec      string
+	kbit       int // 0 = auto
+	channels   int
+	sampleRate int
+	mute       bool
+	filters    []string // per-track -af chains, set by processing stages such as loudnorm
+	tempo      string   // atempo chain for a speed change, applied to every track
+	edit       string   // cut list and fade chain, applied to every track before the tempo
+}
+
+// keepsSource reports whether the settings leave the source audio format
+// alone, which is required to stream-copy it.
+func (a audioSettings) keepsSource() bool {
+	return a.codec == "" && a.channels == 0 && a.sampleRate == 0 && len(a.filters) == 0 && a.tempo == "" && a.edit == ""
+}
+
+// bitrate returns the audio bitrate in kbit/s. In auto mode it takes a share
+// of totalRate (bits/s, 0 in CRF mode) so tiny targets keep most of their
+// budget for video and large ones don't starve the audio.
+func (a audioSettings) bitrate(codec string, totalRate float64) int {
+	if a.kbit > 0 {
+		return a.kbit
+	}
+	if totalRate <= 0 {
+		return 128
+	}
+
+	minKBit, maxKBit := 32.0, 192.0
+	if codec == "opus" {
+		minKBit = 16 // opus stays usable far lower than aac/mp3
+	}
+	if a.channels == 1 {
+		maxKBit /= 2
+	}
+
+	kbit := totalRate / 1000 * 0.12
+	kbit = math.Max(minKBit, math.Min(maxKBit, kbit))
+	// never take more than half of the total
+	kbit = math.Min(kbit, math.Max(minKBit/2, totalRate/1000/2))
+	return int(math.Round(kbit/8) * 8)
+}
+
+// losslessCodecs have no bitrate to set; their size depends on the sound.
+var losslessCodecs = []string{"flac", "alac"}
+
+// losslessRatio is the share of the raw 16-bit PCM rate FLAC and ALAC
+// typically need.
+const losslessRatio = 0.6
+
+// losslessRate estimates the bits/s of st encoded losslessly with the
+// settings' channel and sample rate changes, to budget for it.
+func (a audioSettings) losslessRate(st *probeStream) float64 {
+	rate, channels := 48000, 2
+	if st != nil {
+		if r, _ := strconv.Atoi(st.SampleRate); r > 0 {
+			rate = r
+		}
+		if st.Channels > 0 {
+			channels = st.Channels
+		}
+	}
+	if a.sampleRate > 0 {
+		rate = a.sampleRate
+	}
+	if a.channels > 0 {
+		channels = a.channels
+	}
+	return float64(rate*channels*16) * losslessRatio
+}
+
+// trackBitrate is bitrate for each of several audio tracks. An automatic
+// share of a size budget is split between them; a fixed -ab applies per track.
+func (a audioSettings) trackBitrate(codec string, totalRate float64, tracks int) int {
+	kbit := a.bitrate(codec, totalRate)
+	if a.kbit > 0 || totalRate <= 0 || tracks <= 1 {
+		return kbit
+	}
+	minKBit := 32.0
+	if codec == "opus" {
+		minKBit = 16
+	}
+	perTrack := math.Max(minKBit, float64(kbit)/float64(tracks))
+	return int(math.Round(perTrack/8) * 8)
+}
+
+// args returns the FFmpeg audio arguments for encoding tracks audio streams
+// with encoder at kbit each. Each track gets its own filter (loudnorm,
+// measured per track) after the edit and tempo, which apply to all of them.
+func (a audioSettings) args(encoder string, kbit, tracks int) []string {
+	args := []string{"-c:a", encoder}
+	if !slices.Contains(losslessCodecs, encoder) {
+		args = append(args, "-b:a", strconv.Itoa(kbit)+"k")
+	}
+	shared := joinChains(a.edit, a.tempo)
+	for i := range tracks {
+		f := shared
+		if i < len(a.filters) {
+			f = joinChains(shared, a.filters[i])
+		}
+		switch {
+		case f == "":
+		case tracks == 1:
+			args = append(args, "-af", f)
+		default:
+			args = append(args, fmt.Sprintf("-filter:a:%d", i), f)
+		}
+	}
+	if a.channels > 0 {
+		args = append(args, "-ac", strconv.Itoa(a.channels))
+	}
+	if a.sampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(a.sampleRate))
+	}
+	return args
+}
+
+// parseChannels accepts "mono", "stereo" or a channel count.
+func parseChannels(s string) int {
+	switch s {
+	case "mono":
+		return 1
+	case "stereo":
+		return 2
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 8 {
+		return 0
+	}
+	return n
+}
+
+// joinChains joins filter chains, skipping empty ones.
+func joinChains(chains ...string) string {
+	var parts []string
+	for _, c := range chains {
+		if c != "" {
+			parts = append(parts, c)
+		}
+	}
+	return strings.Join(parts, ",")
+}
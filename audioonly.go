@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type audioOutput struct {
+	Ext    string
+	Format string
+	Cover  bool // can carry an attached cover picture
+}
+
+// audioOutputs are the files audio mode writes, by codec.
+var audioOutputs = map[string]audioOutput{
+	"opus":   {".opus", "opus", false},
+	"aac":    {".m4a", "ipod", true},
+	"mp3":    {".mp3", "mp3", true},
+	"vorbis": {".ogg", "ogg", false},
+	"flac":   {".flac", "flac", true},
+}
+
+// audioCodecFromExt picks the audio mode codec from an -o extension.
+func audioCodecFromExt(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	for codec, out := range audioOutputs {
+		if out.Ext == ext {
+			return codec
+		}
+	}
+	switch ext {
+	case ".aac", ".mp4":
+		return "aac"
+	case ".oga":
+		return "vorbis"
+	}
+	return ""
+}
+
+// audioQualityKBit maps the quality slider (0 = best, 10 = smallest) to a
+// bitrate.
+func audioQualityKBit(level int) int {
+	kbit := 192 * math.Pow(0.8, float64(level))
+	return int(math.Round(kbit/8) * 8)
+}
+
+// audioOnlyBitrate returns the bitrate for audio mode, from the target size
+// if there is one. It warns when the codec's limits keep it from hitting the
+// target.
+func audioOnlyBitrate(opts encodeOptions, codec string, duration float64) (int, []string) {
+	if opts.targetMB <= 0 {
+		if opts.audio.kbit > 0 {
+			return opts.audio.kbit, nil
+		}
+		return audioQualityKBit(opts.crfSlider), nil
+	}
+
+	minKBit, maxKBit := 32.0, 320.0
+	if codec == "opus" {
+		minKBit, maxKBit = 6, 510
+	}
+	kbit := opts.targetMB * 8388608 / duration / 1000 * 0.97 // leave room for the container
+	var warnings []string
+	if kbit < minKBit {
+		warnings = append(warnings, fmt.Sprintf("%g MB is too small for %.0f seconds of %s audio; using the minimum %.0f kbit/s", opts.targetMB, duration, codec, minKBit))
+	}
+	return int(math.Max(minKBit, math.Min(maxKBit, kbit))), warnings
+}
+
+// audioOnlyArgs builds the command for audio mode. With cover set, an
+// attached picture in the input is carried over if the format allows it.
+func audioOnlyArgs(opts encodeOptions, info *FFProbeOutput, trimArgs []string, kbit int, outputFile string) ([]string, []string, error) {
+	if opts.streams == nil || len(opts.streams.audio) == 0 {
+		return nil, nil, fmt.Errorf("the input has no audio track")
+	}
+	codec := opts.audio.codec
+	out, ok := audioOutputs[codec]
+	if !ok {
+		return nil, nil, fmt.Errorf("audio mode can't write %s: use opus, aac, mp3, vorbis or flac", codec)
+	}
+
+	args := []string{"-y"}
+	args = append(args, trimArgs...)
+	args = append(args, "-i", opts.inputFile, "-map", fmt.Sprintf("0:%d", opts.streams.audio[0]))
+
+	var warnings []string
+	cover := coverStream(info)
+	if opts.cover && cover >= 0 {
+		if out.Cover {
+			args = append(args, "-map", "0:"+strconv.Itoa(cover), "-c:v", "copy", "-disposition:v", "attached_pic")
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s files can't hold cover art, dropping it", out.Ext))
+		}
+	}
+
+	args = append(args, opts.audio.args(audioEncoders[codec], kbit, 1)...)
+	if codec == "mp3" {
+		args = append(args, "-id3v2_version", "3")
+	}
+	args = append(args, "-f", out.Format, outputFile)
+	return args, warnings, nil
+}
+
+// coverStream returns the index of the input's attached picture, or -1.
+func coverStream(info *FFProbeOutput) int {
+	for _, st := range info.Streams {
+		if st.CodecType == "video" && st.Disposition["attached_pic"] == 1 {
+			return st.Index
+		}
+	}
+	return -1
+}
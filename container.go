@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type containerInfo struct {
+	Name      string
+	Ext       string
+	Format    string   // FFmpeg muxer for -f
+	Video     []string // accepted video codec families, nil = any
+	Audio     []string // accepted audio codecs, nil = any
+	Faststart bool     // supports -movflags +faststart
+	Subtitle  string   // codec text subtitles are converted to, "" = copy any
+}
+
+var containers = map[string]containerInfo{
+	"mp4":  {"mp4", ".mp4", "mp4", []string{"h264", "hevc", "av1", "vp9"}, []string{"aac", "opus", "mp3", "ac3", "flac", "alac"}, true, "mov_text"},
+	"mov":  {"mov", ".mov", "mov", []string{"h264", "hevc"}, []string{"aac", "mp3", "alac", "ac3"}, true, "mov_text"},
+	"webm": {"webm", ".webm", "webm", []string{"vp9", "av1"}, []string{"opus", "vorbis"}, false, "webvtt"},
+	"mkv":  {"mkv", ".mkv", "matroska", nil, nil, false, ""},
+}
+
+// containerNames is the order containers are listed in help and errors.
+var containerNames = []string{"mp4", "mkv", "webm", "mov"}
+
+// audioEncoders maps an audio codec name to the FFmpeg encoder for it.
+var audioEncoders = map[string]string{
+	"aac":    "aac",
+	"opus":   "libopus",
+	"mp3":    "libmp3lame",
+	"vorbis": "libvorbis",
+	"flac":   "flac",
+	"ac3":    "ac3",
+	"alac":   "alac",
+}
+
+// containerFromExt returns the container for an output path's extension, or
+// "" if the extension isn't one of ours.
+func containerFromExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".m4v":
+		return "mp4"
+	case ".mkv":
+		return "mkv"
+	case ".webm":
+		return "webm"
+	case ".mov":
+		return "mov"
+	}
+	return ""
+}
+
+// videoFamily returns the codec an FFmpeg video encoder produces.
+func videoFamily(lib string) string {
+	switch {
+	case strings.Contains(lib, "264"):
+		return "h264"
+	case strings.Contains(lib, "hevc"), strings.Contains(lib, "265"):
+		return "hevc"
+	case strings.Contains(lib, "av1"):
+		return "av1"
+	case strings.Contains(lib, "vp9"):
+		return "vp9"
+	}
+	return lib
+}
+
+// defaultAudioCodec is the audio codec used for a container when the user
+// hasn't picked one.
+func (c containerInfo) defaultAudioCodec() string {
+	switch c.Name {
+	case "mp4", "mov":
+		return "aac"
+	}
+	return "opus"
+}
+
+func (c containerInfo) acceptsAudio(codec string) bool {
+	return c.Audio == nil || slices.Contains(c.Audio, codec)
+}
+
+// checkContainer validates a video encoder and audio codec against c. An
+// unsupported video codec is an error; an unsupported audio codec is
+// replaced with the container's default and reported as a warning.
+func checkContainer(c containerInfo, videoLib, audioCodec string) (string, []string, error) {
+	if family := videoFamily(videoLib); c.Video != nil && !slices.Contains(c.Video, family) {
+		return "", nil, fmt.Errorf("%s can't hold %s video; use %s or pick another codec", strings.ToUpper(c.Name), strings.ToUpper(family), containersFor(family))
+	}
+
+	var warnings []string
+	if audioCodec == "" {
+		audioCodec = c.defaultAudioCodec()
+	} else if !c.acceptsAudio(audioCodec) {
+		fallback := c.defaultAudioCodec()
+		warnings = append(warnings, fmt.Sprintf("%s audio isn't supported in %s, using %s instead", strings.ToUpper(audioCodec), strings.ToUpper(c.Name), strings.ToUpper(fallback)))
+		audioCodec = fallback
+	}
+	return audioCodec, warnings, nil
+}
+
+// containersFor lists the containers that accept a video codec family.
+func containersFor(family string) string {
+	var names []string
+	for _, name := range containerNames {
+		if c := containers[name]; c.Video == nil || slices.Contains(c.Video, family) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
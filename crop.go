@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// cropRect is a crop=W:H:X:Y rectangle in source pixels.
+type cropRect struct {
+	W, H, X, Y int
+}
+
+func (r cropRect) String() string {
+	return fmt.Sprintf("%d:%d:%d:%d", r.W, r.H, r.X, r.Y)
+}
+
+func (r cropRect) filter() videoFilter {
+	return newFilter(stageCrop, "crop", pos(strconv.Itoa(r.W)), pos(strconv.Itoa(r.H)), pos(strconv.Itoa(r.X)), pos(strconv.Itoa(r.Y)))
+}
+
+// cropAnchors are the positions an aspect-ratio crop can keep.
+var cropAnchors = []string{"center", "top", "bottom", "left", "right", "top-left", "top-right", "bottom-left", "bottom-right"}
+
+// parseCrop reads a crop: "W:H:X:Y", or an aspect ratio such as "9:16" with
+// an optional anchor ("9:16 top", "4:5:bottom") cut from a w x h source.
+// An empty spec or "none" means no crop.
+func parseCrop(spec string, w, h int) (*cropRect, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" || spec == "none" || spec == "off" {
+		return nil, nil
+	}
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ':' || r == ' ' })
+
+	var nums []int
+	anchor := "center"
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		switch {
+		case err == nil && n >= 0:
+			nums = append(nums, n)
+		case i == len(fields)-1 && len(nums) == 2 && slices.Contains(cropAnchors, f):
+			anchor = f
+		default:
+			return nil, fmt.Errorf("invalid crop %q: use W:H:X:Y or an aspect ratio like 9:16 [top|bottom|left|right]", spec)
+		}
+	}
+
+	var r cropRect
+	switch len(nums) {
+	case 4:
+		r = cropRect{nums[0], nums[1], nums[2], nums[3]}
+	case 2:
+		if nums[0] == 0 || nums[1] == 0 {
+			return nil, fmt.Errorf("invalid aspect ratio %q", spec)
+		}
+		if w <= 0 || h <= 0 {
+			return nil, fmt.Errorf("the source size is unknown, can't crop to an aspect ratio")
+		}
+		r = aspectCrop(float64(nums[0])/float64(nums[1]), anchor, w, h)
+	default:
+		return nil, fmt.Errorf("invalid crop %q: use W:H:X:Y or an aspect ratio like 9:16 [top|bottom|left|right]", spec)
+	}
+
+	if r.W <= 0 || r.H <= 0 {
+		return nil, fmt.Errorf("crop %s is empty", r)
+	}
+	if w > 0 && h > 0 && (r.X+r.W > w || r.Y+r.H > h) {
+		return nil, fmt.Errorf("crop %s doesn't fit in the %dx%d source", r, w, h)
+	}
+	return &r, nil
+}
+
+// aspectCrop is the largest even-sized rectangle of the given aspect ratio
+// in a w x h frame, placed at anchor.
+func aspectCrop(aspect float64, anchor string, w, h int) cropRect {
+	r := cropRect{W: w, H: h}
+	if float64(w)/float64(h) > aspect {
+		r.W = int(float64(h)*aspect) &^ 1
+	} else {
+		r.H = int(float64(w)/aspect) &^ 1
+	}
+
+	r.X, r.Y = (w-r.W)/2, (h-r.H)/2
+	if strings.Contains(anchor, "left") {
+		r.X = 0
+	} else if strings.Contains(anchor, "right") {
+		r.X = w - r.W
+	}
+	if strings.HasPrefix(anchor, "top") {
+		r.Y = 0
+	} else if strings.HasPrefix(anchor, "bottom") {
+		r.Y = h - r.H
+	}
+	return r
+}
+
+// cropSamples and cropSampleLength set how much of the input cropdetect
+// looks at: a few short segments spread over the whole range, so a dark
+// intro or a single letterboxed scene doesn't decide for the whole file.
+const (
+	cropSamples      = 5
+	cropSampleLength = 2.0
+)
+
+var cropdetectRe = regexp.MustCompile(`crop=(\d+):(\d+):(\d+):(\d+)`)
+
+// detectCrop runs cropdetect over samples of the range [start, start+duration)
+// and returns the smallest rectangle holding the picture in all of them, or
+// nil if there are no bars to remove from the w x h frame.
+func detectCrop(file string, video, w, h int, start, duration float64, ch chan<- progressMsg, logPath string) (*cropRect, error) {
+	if duration <= 0 {
+		return nil, fmt.Errorf("unknown duration")
+	}
+	length := min(cropSampleLength, duration/cropSamples)
+
+	var found *cropRect
+	for i := range cropSamples {
+		at := start + duration*(float64(i)+0.5)/cropSamples - length/2
+		args := []string{"-ss", strconv.FormatFloat(at, 'f', 3, 64), "-t", strconv.FormatFloat(length, 'f', 3, 64), "-i", file,
+			"-map", fmt.Sprintf("0:%d", video), "-vf", "cropdetect=round=2", "-f", "null", "-"}
+		stderr, err := runFFmpegOutput(args, ch, length, fmt.Sprintf("Crop Detection (%d/%d)", i+1, cropSamples), logPath)
+		if err != nil {
+			return nil, err
+		}
+
+		m := cropdetectRe.FindAllStringSubmatch(stderr, -1)
+		if m == nil {
+			continue // too short or all black
+		}
+		last := m[len(m)-1]
+		n := func(i int) int { v, _ := strconv.Atoi(last[i]); return v }
+		r := cropRect{n(1), n(2), n(3), n(4)}
+		if found == nil {
+			found = &r
+			continue
+		}
+		x2, y2 := max(found.X+found.W, r.X+r.W), max(found.Y+found.H, r.Y+r.H)
+		found.X, found.Y = min(found.X, r.X), min(found.Y, r.Y)
+		found.W, found.H = (x2-found.X)&^1, (y2-found.Y)&^1
+	}
+
+	if found == nil || (found.W >= w && found.H >= h) {
+		return nil, nil
+	}
+	return found, nil
+}
+
+// cropDetectedMsg carries the wizard's crop detection result.
+type cropDetectedMsg struct {
+	file string
+	rect *cropRect
+	err  error
+}
+
+// detectCropCmd runs crop detection for the crop step in the background.
+func (m model) detectCropCmd() tea.Cmd {
+	v := m.info.stream(m.streams.video)
+	if v == nil {
+		return nil
+	}
+	file, w, h := m.filePath, v.Width, v.Height
+	start, duration := encodeOptions{cuts: m.cuts}.sourceWindow(m.info)
+	video := v.Index
+	return func() tea.Msg {
+		rect, err := detectCrop(file, video, w, h, start, duration, nil, "")
+		return cropDetectedMsg{file: file, rect: rect, err: err}
+	}
+}
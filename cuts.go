@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// keepRange is a part of the source to keep, in source seconds.
+type keepRange struct {
+	start, end float64
+}
+
+func (r keepRange) length() float64 {
+	return r.end - r.start
+}
+
+// parseCutList reads keep ranges such as "0:10-0:20, 1:00-1:30", separated
+// by commas or newlines, from a source of the given duration and frame rate.
+// Points take any parseTimestamp form. Lines starting with # are comments.
+func parseCutList(s string, duration, fps float64) ([]keepRange, error) {
+	var ranges []keepRange
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, part := range strings.Split(line, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			from, to, ok := splitRange(part)
+			if !ok {
+				return nil, fmt.Errorf("invalid range %q: use start-end, e.g. 1:00-1:30", part)
+			}
+			r := keepRange{0, duration}
+			var err error
+			if from != "" {
+				if r.start, err = parseTimestamp(from, duration, fps); err != nil {
+					return nil, err
+				}
+			}
+			if to != "" {
+				if r.end, err = parseTimestamp(to, duration, fps); err != nil {
+					return nil, err
+				}
+			} else if duration <= 0 {
+				return nil, fmt.Errorf("the duration is unknown, range %q needs an end", part)
+			}
+			if r.end <= r.start {
+				return nil, fmt.Errorf("invalid range %q: the end must come after the start", part)
+			}
+			ranges = append(ranges, r)
+		}
+	}
+
+	slices.SortFunc(ranges, func(a, b keepRange) int { return cmpFloat(a.start, b.start) })
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i].start < ranges[i-1].end {
+			return nil, fmt.Errorf("ranges %s and %s overlap", ranges[i-1], ranges[i])
+		}
+	}
+	return ranges, nil
+}
+
+// readCutList reads a -cuts file, one or more ranges per line, for
+// parseCutList.
+func readCutList(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+func (r keepRange) String() string {
+	return formatSeconds(r.start) + "-" + formatSeconds(r.end)
+}
+
+// formatRanges lists ranges for display, e.g. "10-20, 45.5-60".
+func formatRanges(ranges []keepRange) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatSeconds formats a time for FFmpeg and for display, e.g. "83.5".
+func formatSeconds(sec float64) string {
+	return strconv.FormatFloat(sec, 'f', -1, 64)
+}
+
+// formatDuration formats a length for display, e.g. "1:23.5".
+func formatDuration(sec float64) string {
+	m := int(sec) / 60
+	return fmt.Sprintf("%d:%04.1f", m, sec-float64(m*60))
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// keptDuration is the summed length of ranges.
+func keptDuration(ranges []keepRange) float64 {
+	total := 0.0
+	for _, r := range ranges {
+		total += r.length()
+	}
+	return total
+}
+
+// sourceStart is where the job's input seek lands, 0 without one.
+func (o encodeOptions) sourceStart() float64 {
+	if len(o.cuts) > 0 {
+		return o.cuts[0].start
+	}
+	return 0
+}
+
+// sourceWindow is the stretch of the source the job reads, gaps included.
+func (o encodeOptions) sourceWindow(info *FFProbeOutput) (float64, float64) {
+	ranges := o.cuts
+	if len(ranges) == 0 {
+		total, _ := strconv.ParseFloat(info.Format.Duration, 64)
+		return 0, total
+	}
+	return ranges[0].start, ranges[len(ranges)-1].end - ranges[0].start
+}
+
+// trimArgs seek the input to the span the job covers. A cut list still reads
+// from its first start to its last end; the gaps are dropped by cutFilters.
+func (o encodeOptions) trimArgs() []string {
+	ranges := o.cuts
+	if len(ranges) == 0 {
+		return []string{}
+	}
+	return []string{"-ss", formatSeconds(ranges[0].start), "-to", formatSeconds(ranges[len(ranges)-1].end)}
+}
+
+// gapExpr is the time cut out before the timestamp variable v, in seconds.
+// v is measured from the input seek point, or taken on the source timeline
+// when source is set.
+func gapExpr(ranges []keepRange, v string, source bool) string {
+	base := ranges[0].start
+	var terms []string
+	for i := 1; i < len(ranges); i++ {
+		at := ranges[i].start - base
+		if source {
+			at = ranges[i].start
+		}
+		terms = append(terms, fmt.Sprintf("gte(%s,%g)*%g", v, at, ranges[i].start-ranges[i-1].end))
+	}
+	if len(terms) == 0 {
+		return "0"
+	}
+	return strings.Join(terms, "+")
+}
+
+// cutFilters keep only ranges from a stream seeked to the first of them and
+// close the gaps, for video and for an audio -af chain. Timestamps are
+// shifted rather than renumbered, so variable frame rate sources and the
+// audio stay in sync. Ranges are half-open so a join never holds two frames
+// with the same timestamp. Audio is split into small frames first so a cut can't
+// fall in the middle of one.
+func cutFilters(ranges []keepRange) ([]videoFilter, []videoFilter) {
+	base := ranges[0].start
+	var keep []string
+	for _, r := range ranges {
+		keep = append(keep, fmt.Sprintf("gte(t,%g)*lt(t,%g)", r.start-base, r.end-base))
+	}
+	selectExpr := strings.Join(keep, "+")
+	shift := "PTS-(" + gapExpr(ranges, "T", false) + ")/TB"
+
+	video := []videoFilter{
+		newFilter(stageCut, "select", pos(selectExpr)),
+		newFilter(stageCut, "setpts", pos(shift)),
+	}
+	audio := []videoFilter{
+		newFilter(stageCut, "asetnsamples", kv("n", "64"), kv("p", "0")),
+		newFilter(stageCut, "aselect", pos(selectExpr)),
+		newFilter(stageCut, "asetpts", pos(shift)),
+	}
+	return video, audio
+}
+
+// fadeFilters fade video and audio in and out over d seconds at the start
+// and end of every kept range, on the output timeline. fade and volume only
+// act inside their window, so one filter per edge is enough.
+func fadeFilters(lengths []float64, d float64) ([]videoFilter, []videoFilter) {
+	var video []videoFilter
+	var gains []string
+	at := 0.0
+	for _, l := range lengths {
+		fd := min(d, l/2)
+		in, out := at, at+l-fd
+		video = append(video,
+			newFilter(stageCut, "fade", kv("t", "in"), kv("st", fmt.Sprintf("%g", in)), kv("d", fmt.Sprintf("%g", fd)), kv("enable", fmt.Sprintf("between(t,%g,%g)", in, in+fd))),
+			newFilter(stageCut, "fade", kv("t", "out"), kv("st", fmt.Sprintf("%g", out)), kv("d", fmt.Sprintf("%g", fd)), kv("enable", fmt.Sprintf("between(t,%g,%g)", out, out+fd))))
+		gains = append(gains,
+			fmt.Sprintf("if(between(t,%g,%g),(t-%g)/%g,1)", in, in+fd, in, fd),
+			fmt.Sprintf("if(between(t,%g,%g),(%g-t)/%g,1)", out, out+fd, out+fd, fd))
+		at += l
+	}
+	audio := []videoFilter{newFilter(stageCut, "volume", kv("volume", strings.Join(gains, "*")), kv("eval", "frame"))}
+	return video, audio
+}
+
+// editFilters are the video and audio filters for the job's cut list and
+// fades; both are empty for a plain encode.
+func (o encodeOptions) editFilters(info *FFProbeOutput) ([]videoFilter, []videoFilter) {
+	var video, audio []videoFilter
+	ranges := o.cuts
+	if len(ranges) > 1 {
+		video, audio = cutFilters(ranges)
+	}
+	if o.fade > 0 {
+		var lengths []float64
+		for _, r := range ranges {
+			lengths = append(lengths, r.length())
+		}
+		if len(lengths) == 0 {
+			total, _ := strconv.ParseFloat(info.Format.Duration, 64)
+			lengths = []float64{total}
+		}
+		if lengths[0] > 0 {
+			v, a := fadeFilters(lengths, o.fade)
+			video, audio = append(video, v...), append(audio, a...)
+		}
+	}
+	return video, audio
+}
+
+// timeMap converts between the output timeline and the source's, for
+// filters such as subtitles that need source times.
+type timeMap struct {
+	ranges []keepRange // nil = the whole source
+	speed  float64
+}
+
+// toSource is a setpts expression moving output timestamps onto the source
+// timeline.
+func (tm timeMap) toSource() string {
+	expr := "PTS"
+	if tm.speed != 1 {
+		expr = fmt.Sprintf("PTS*%g", tm.speed)
+	}
+	if len(tm.ranges) == 0 {
+		return expr
+	}
+	if tm.ranges[0].start > 0 {
+		expr += fmt.Sprintf("+%g/TB", tm.ranges[0].start)
+	}
+	// the gaps are found from the cut timeline, where each range starts at
+	// the summed length of those before it
+	var terms []string
+	at := tm.ranges[0].length()
+	for i := 1; i < len(tm.ranges); i++ {
+		terms = append(terms, fmt.Sprintf("gte(T*%g,%g)*%g", tm.speed, at, tm.ranges[i].start-tm.ranges[i-1].end))
+		at += tm.ranges[i].length()
+	}
+	if len(terms) > 0 {
+		expr += "+(" + strings.Join(terms, "+") + ")/TB"
+	}
+	return expr
+}
+
+// toOutput is the inverse of toSource.
+func (tm timeMap) toOutput() string {
+	expr := "PTS"
+	if len(tm.ranges) > 0 {
+		if tm.ranges[0].start > 0 {
+			expr += fmt.Sprintf("-%g/TB", tm.ranges[0].start)
+		}
+		if len(tm.ranges) > 1 {
+			expr += "-(" + gapExpr(tm.ranges, "T", true) + ")/TB"
+		}
+	}
+	if tm.speed != 1 {
+		expr = fmt.Sprintf("(%s)/%g", expr, tm.speed)
+	}
+	return expr
+}
+
+func (tm timeMap) identity() bool {
+	return tm.speed == 1 && (len(tm.ranges) == 0 || (len(tm.ranges) == 1 && tm.ranges[0].start == 0))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCutList(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []keepRange
+		wantErr bool
+	}{
+		{"0:10-0:20, 1:00-1:30", []keepRange{{10, 20}, {60, 90}}, false},
+		{"1:00-1:30\n# intro\n0:10-0:20\n", []keepRange{{10, 20}, {60, 90}}, false},
+		{"300f-600f", []keepRange{{10, 20}}, false},
+		{"-30-", []keepRange{{570, 600}}, false},
+		{"10 -5", []keepRange{{10, 595}}, false},
+		{"", nil, false},
+		{"20-10", nil, true},
+		{"10-20, 15-30", nil, true},
+		{"10", nil, true},
+		{"10-700", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCutList(tt.in, 600, 30)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCutList(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !slices.Equal(got, tt.want) {
+			t.Errorf("parseCutList(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseCutListUnknownDuration(t *testing.T) {
+	if _, err := parseCutList("10-", 0, 0); err == nil {
+		t.Error("parseCutList with an open range and no duration succeeded")
+	}
+}
+
+func TestTimeMap(t *testing.T) {
+	tests := []struct {
+		tm                 timeMap
+		toSource, toOutput string
+	}{
+		{timeMap{nil, 1}, "PTS", "PTS"},
+		{timeMap{nil, 2}, "PTS*2", "(PTS)/2"},
+		{timeMap{[]keepRange{{10, 20}}, 1}, "PTS+10/TB", "PTS-10/TB"},
+		{timeMap{[]keepRange{{0, 10}, {20, 30}}, 1}, "PTS+(gte(T*1,10)*10)/TB", "PTS-(gte(T,20)*10)/TB"},
+		{timeMap{[]keepRange{{5, 10}, {20, 30}}, 2}, "PTS*2+5/TB+(gte(T*2,5)*10)/TB", "(PTS-5/TB-(gte(T,20)*10)/TB)/2"},
+	}
+	for _, tt := range tests {
+		if got := tt.tm.toSource(); got != tt.toSource {
+			t.Errorf("%v toSource() = %q, want %q", tt.tm, got, tt.toSource)
+		}
+		if got := tt.tm.toOutput(); got != tt.toOutput {
+			t.Errorf("%v toOutput() = %q, want %q", tt.tm, got, tt.toOutput)
+		}
+	}
+}
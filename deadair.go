@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// deadAirModes are the -deadair values: trim dead air at both ends, or also
+// cut long stretches of it from the middle.
+var deadAirModes = []string{"ends", "all"}
+
+// Dead air is a stretch where the picture is black or frozen and the sound
+// is silent. Only the first and last deadAirWindow seconds are analyzed for
+// the ends; the whole file is when the middle is cut too.
+const (
+	deadAirWindow  = 60.0
+	deadAirMinEdge = 0.5 // shorter dead air at an end isn't worth trimming
+	deadAirMinGap  = 5.0 // shorter dead air in the middle is left in
+	deadAirPad     = 0.25
+)
+
+// deadAirRe matches the events of blackdetect, freezedetect and
+// silencedetect, in the order FFmpeg logs them.
+var deadAirRe = regexp.MustCompile(`(black|freeze|silence)_(start|end):\s*(-?[0-9.]+)`)
+
+// deadAirReport is what dead air detection found in a file.
+type deadAirReport struct {
+	dead []keepRange // dead stretches, in source seconds
+	keep []keepRange // proposed keep ranges, nil if there's nothing to cut
+}
+
+// detectDeadAir looks for dead air in the video stream video and the audio
+// stream audio of a file lasting duration seconds; either may be -1. With
+// middle set, long stretches inside the file are proposed for cutting too.
+func detectDeadAir(file string, video, audio int, duration float64, middle bool, ch chan<- progressMsg, logPath string) (deadAirReport, error) {
+	if duration <= 0 {
+		return deadAirReport{}, fmt.Errorf("unknown duration")
+	}
+	if video < 0 && audio < 0 {
+		return deadAirReport{}, fmt.Errorf("no stream to analyze")
+	}
+	windows := []keepRange{{0, duration}}
+	if !middle && duration > 2*deadAirWindow {
+		windows = []keepRange{{0, deadAirWindow}, {duration - deadAirWindow, duration}}
+	}
+
+	var picture, silence []keepRange
+	for i, w := range windows {
+		args := []string{"-ss", formatSeconds(w.start), "-t", formatSeconds(w.length()), "-i", file}
+		if video >= 0 {
+			p := filterPipeline{}
+			p.add(newFilter(stageExtra, "blackdetect", kv("d", "0.1"), kv("pix_th", "0.10")),
+				newFilter(stageExtra, "freezedetect", kv("n", "-60dB"), kv("d", "0.5")))
+			args = append(args, "-map", fmt.Sprintf("0:%d", video), "-vf", p.chain())
+		}
+		if audio >= 0 {
+			args = append(args, "-map", fmt.Sprintf("0:%d", audio), "-af", "silencedetect=n=-50dB:d=0.5")
+		}
+		stage := "Dead Air Detection"
+		if len(windows) > 1 {
+			stage += fmt.Sprintf(" (%d/%d)", i+1, len(windows))
+		}
+		stderr, err := runFFmpegOutput(append(args, "-f", "null", "-"), ch, w.length(), stage, logPath)
+		if err != nil {
+			return deadAirReport{}, err
+		}
+
+		found := parseDeadAir(stderr, w)
+		picture = append(picture, unionRanges(found["black"], found["freeze"])...)
+		silence = append(silence, found["silence"]...)
+	}
+
+	var dead []keepRange
+	switch {
+	case video < 0:
+		dead = unionRanges(silence, nil)
+	case audio < 0:
+		dead = unionRanges(picture, nil)
+	default:
+		dead = intersectRanges(unionRanges(picture, nil), unionRanges(silence, nil))
+	}
+	return deadAirReport{dead: dead, keep: proposeKeep(dead, duration, middle)}, nil
+}
+
+// parseDeadAir collects the stretches each detector logged while analyzing
+// window, moved onto the source timeline. One still open at the end of the
+// window lasts to its end.
+func parseDeadAir(log string, w keepRange) map[string][]keepRange {
+	found := map[string][]keepRange{}
+	open := map[string]float64{}
+	for _, m := range deadAirRe.FindAllStringSubmatch(log, -1) {
+		t, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			continue
+		}
+		t = min(max(w.start+t, w.start), w.end)
+		if m[2] == "start" {
+			open[m[1]] = t
+		} else if start, ok := open[m[1]]; ok {
+			found[m[1]] = append(found[m[1]], keepRange{start, t})
+			delete(open, m[1])
+		}
+	}
+	for kind, start := range open {
+		found[kind] = append(found[kind], keepRange{start, w.end})
+	}
+	return found
+}
+
+// unionRanges merges a and b into sorted, non-overlapping ranges.
+func unionRanges(a, b []keepRange) []keepRange {
+	all := slices.Concat(a, b)
+	slices.SortFunc(all, func(x, y keepRange) int { return cmpFloat(x.start, y.start) })
+	var merged []keepRange
+	for _, r := range all {
+		if n := len(merged); n > 0 && r.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, r.end)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// intersectRanges is the time covered by both a and b, which must be sorted
+// and non-overlapping.
+func intersectRanges(a, b []keepRange) []keepRange {
+	var both []keepRange
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		r := keepRange{max(a[i].start, b[j].start), min(a[i].end, b[j].end)}
+		if r.length() > 0 {
+			both = append(both, r)
+		}
+		if a[i].end < b[j].end {
+			i++
+		} else {
+			j++
+		}
+	}
+	return both
+}
+
+// proposeKeep turns dead stretches into keep ranges for a file lasting
+// duration seconds, leaving deadAirPad of each cut stretch so the edit
+// doesn't feel abrupt. It returns nil when nothing is worth cutting.
+func proposeKeep(dead []keepRange, duration float64, middle bool) []keepRange {
+	const edge = 0.1 // detectors may start or stop a frame off the end
+	keep := keepRange{0, duration}
+	for _, d := range dead {
+		if d.length() < deadAirMinEdge {
+			continue
+		}
+		if d.start <= edge {
+			keep.start = max(keep.start, d.end-deadAirPad)
+		}
+		if d.end >= duration-edge {
+			keep.end = min(keep.end, d.start+deadAirPad)
+		}
+	}
+	if keep.length() <= 0 {
+		return nil // all dead air; let the user decide
+	}
+
+	ranges := []keepRange{keep}
+	if middle {
+		for _, d := range dead {
+			last := &ranges[len(ranges)-1]
+			if d.length() < deadAirMinGap || d.start-deadAirPad <= last.start || d.end+deadAirPad >= last.end {
+				continue
+			}
+			end := last.end
+			last.end = d.start + deadAirPad
+			ranges = append(ranges, keepRange{d.end - deadAirPad, end})
+		}
+	}
+	if len(ranges) == 1 && keep.start == 0 && keep.end == duration {
+		return nil
+	}
+	return ranges
+}
+
+// summary describes what the proposal cuts, e.g. "3.2s at the start, 1
+// stretch of 12.0s in the middle".
+func (r deadAirReport) summary(duration float64) string {
+	if len(r.keep) == 0 {
+		return "no dead air"
+	}
+	var parts []string
+	if r.keep[0].start > 0 {
+		parts = append(parts, fmt.Sprintf("%.1fs at the start", r.keep[0].start))
+	}
+	if n := len(r.keep) - 1; n > 0 {
+		gaps := r.keep[len(r.keep)-1].start - r.keep[0].end - keptDuration(r.keep[1:len(r.keep)-1])
+		unit := "stretches"
+		if n == 1 {
+			unit = "stretch"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s of %.1fs in the middle", n, unit, gaps))
+	}
+	if end := r.keep[len(r.keep)-1].end; end < duration {
+		parts = append(parts, fmt.Sprintf("%.1fs at the end", duration-end))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// deadAirStreams are the streams dead air detection looks at: the video
+// stream, unless only audio is kept, and the first kept audio track.
+func deadAirStreams(info *FFProbeOutput, sel streamSelection, mode outputMode) (int, int) {
+	video, audio := -1, -1
+	if v := info.stream(sel.video); v != nil && mode != modeAudio {
+		video = v.Index
+	}
+	if len(sel.audio) > 0 {
+		audio = sel.audio[0]
+	}
+	return video, audio
+}
+
+// trimDeadAir applies -deadair to a job the wizard didn't propose it for,
+// keeping what detectDeadAir suggests.
+func (o *encodeOptions) trimDeadAir(info *FFProbeOutput, ch chan<- progressMsg, logPath string) {
+	mode := o.deadAir
+	o.deadAir = ""
+	if mode == "" || len(o.cuts) > 0 {
+		return
+	}
+	ch <- progressMsg{line: "Detecting dead air..."}
+	video, audio := deadAirStreams(info, *o.streams, o.mode)
+	duration, _ := strconv.ParseFloat(info.Format.Duration, 64)
+	report, err := detectDeadAir(o.inputFile, video, audio, duration, mode == "all", ch, logPath)
+	switch {
+	case err != nil:
+		ch <- progressMsg{kind: evWarning, line: "dead air detection failed, not trimming: " + err.Error()}
+	case report.keep == nil:
+		ch <- progressMsg{line: "No dead air found"}
+	default:
+		ch <- progressMsg{line: fmt.Sprintf("Trimming dead air (%s): %s", report.summary(duration), formatRanges(report.keep))}
+		o.cuts = report.keep
+	}
+}
+
+// deadAirDetectedMsg carries the wizard's dead air detection result.
+type deadAirDetectedMsg struct {
+	file   string
+	report deadAirReport
+	err    error
+}
+
+// detectDeadAirCmd runs dead air detection for the trim step in the
+// background.
+func (m model) detectDeadAirCmd() tea.Cmd {
+	if m.deadAir == "" || len(m.joinFiles) > 1 {
+		return nil
+	}
+	file, middle := m.filePath, m.deadAir == "all"
+	video, audio := deadAirStreams(m.info, m.streams, m.outputMode)
+	duration, _ := strconv.ParseFloat(m.info.Format.Duration, 64)
+	return func() tea.Msg {
+		report, err := detectDeadAir(file, video, audio, duration, middle, nil, "")
+		return deadAirDetectedMsg{file: file, report: report, err: err}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type dedupeMode string
+
+const (
+	dedupeAuto dedupeMode = "auto"
+	dedupeOn   dedupeMode = "on"
+	dedupeOff  dedupeMode = "off"
+)
+
+var dedupeModes = []dedupeMode{dedupeAuto, dedupeOn, dedupeOff}
+
+// dedupeSettings control duplicate-frame removal with mpdecimate. Zero
+// thresholds keep mpdecimate's defaults.
+type dedupeSettings struct {
+	mode dedupeMode
+	hi   int     // a frame differing by more than hi in any 8x8 block is kept
+	lo   int     // blocks differing by more than lo count towards frac
+	frac float64 // share of blocks over lo that keeps a frame
+}
+
+// parseThresholds reads -dedupe-thresh "hi:lo:frac"; empty fields keep the
+// current value.
+func (d dedupeSettings) parseThresholds(s string) (dedupeSettings, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return d, fmt.Errorf("invalid dedupe thresholds %q, want hi:lo:frac", s)
+	}
+	for i, p := range parts {
+		if p == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return d, fmt.Errorf("invalid dedupe threshold %q", p)
+		}
+		switch i {
+		case 0:
+			d.hi = int(v)
+		case 1:
+			d.lo = int(v)
+		case 2:
+			d.frac = v
+		}
+	}
+	return d, nil
+}
+
+func (d dedupeSettings) filter() videoFilter {
+	var args []filterArg
+	if d.hi > 0 {
+		args = append(args, kv("hi", strconv.Itoa(d.hi)))
+	}
+	if d.lo > 0 {
+		args = append(args, kv("lo", strconv.Itoa(d.lo)))
+	}
+	if d.frac > 0 {
+		args = append(args, kv("frac", strconv.FormatFloat(d.frac, 'g', -1, 64)))
+	}
+	return newFilter(stageDedupe, "mpdecimate", args...)
+}
+
+// frameRateArgs returns the -fps_mode arguments for the output. Removing
+// duplicates only saves anything with variable frame rate output; constant
+// frame rate would put them straight back.
+func frameRateArgs(fpsMode string, dedupe bool) []string {
+	switch {
+	case dedupe:
+		return []string{"-fps_mode", "vfr"}
+	case fpsMode == "cfr" || fpsMode == "vfr":
+		return []string{"-fps_mode", fpsMode}
+	}
+	return nil
+}
+
+// dedupeSample is how much of the input auto mode decimates to decide.
+const dedupeSample = 10.0
+
+// dedupeWorthIt is the share of frames mpdecimate has to drop from the sample
+// for auto mode to turn it on. Screen recordings and slides drop most of
+// their frames; camera footage, even on a tripod, drops almost none because
+// of sensor noise.
+const dedupeWorthIt = 0.3
+
+var finalFrameRe = regexp.MustCompile(`frame=\s*(\d+)`)
+
+// measureDuplicates decimates a sample from the middle of the job's range and
+// returns the share of frames that were dropped.
+func measureDuplicates(opts encodeOptions, info *FFProbeOutput, sel streamSelection, duration float64, ch chan<- progressMsg, logPath string) (float64, error) {
+	v := info.stream(sel.video)
+	if v == nil || v.frameRate() <= 0 || duration <= 0 {
+		return 0, fmt.Errorf("unknown frame rate")
+	}
+
+	length := math.Min(dedupeSample, duration)
+	start := math.Min(duration/3, duration-length)
+	start += opts.sourceStart()
+
+	args := []string{"-y", "-ss", strconv.FormatFloat(start, 'f', 3, 64), "-t", strconv.FormatFloat(length, 'f', 3, 64), "-i", opts.inputFile}
+	args = append(args, "-map", fmt.Sprintf("0:%d", sel.video), "-an", "-sn")
+	args = append(args, "-vf", opts.dedupe.filter().String(), "-fps_mode", "vfr", "-f", "null", "-")
+
+	stderr, err := runFFmpegOutput(args, ch, length, "Dedupe Analysis", logPath)
+	if err != nil {
+		return 0, err
+	}
+	// -nostats still prints the final report
+	m := finalFrameRe.FindAllStringSubmatch(stderr, -1)
+	if m == nil {
+		return 0, fmt.Errorf("no frame count in FFmpeg output")
+	}
+	kept, _ := strconv.ParseFloat(m[len(m)-1][1], 64)
+	expected := length * v.frameRate()
+	return math.Max(0, 1-kept/expected), nil
+}
+
+// resolveDedupe settles whether the job removes duplicate frames, sampling
+// the input in auto mode.
+func resolveDedupe(opts encodeOptions, info *FFProbeOutput, sel streamSelection, duration float64, ch chan<- progressMsg, logPath string) dedupeMode {
+	if opts.fpsMode == "cfr" {
+		if opts.dedupe.mode == dedupeOn {
+			ch <- progressMsg{kind: evWarning, line: "constant frame rate output keeps duplicate frames, turning dedupe off"}
+		}
+		return dedupeOff
+	}
+	if opts.dedupe.mode != dedupeAuto {
+		return opts.dedupe.mode
+	}
+
+	share, err := measureDuplicates(opts, info, sel, duration, ch, logPath)
+	if err != nil {
+		ch <- progressMsg{kind: evWarning, line: fmt.Sprintf("could not measure duplicate frames (%v), leaving dedupe off", err)}
+		return dedupeOff
+	}
+	if share < dedupeWorthIt {
+		ch <- progressMsg{line: fmt.Sprintf("Dedupe off: %.0f%% of frames are duplicates", share*100)}
+		return dedupeOff
+	}
+	ch <- progressMsg{line: fmt.Sprintf("Dedupe on: %.0f%% of frames are duplicates", share*100)}
+	return dedupeOn
+}
+
+// frameRate parses ffprobe's avg_frame_rate, e.g. "30000/1001".
+func (st probeStream) frameRate() float64 {
+	num, den, ok := strings.Cut(st.AvgFrameRate, "/")
+	n, _ := strconv.ParseFloat(num, 64)
+	if !ok {
+		return n
+	}
+	d, _ := strconv.ParseFloat(den, 64)
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type deinterlaceMode string
+
+const (
+	deinterlaceAuto   deinterlaceMode = "auto"
+	deinterlaceOn     deinterlaceMode = "on"
+	deinterlaceOff    deinterlaceMode = "off"
+	deinterlaceDouble deinterlaceMode = "double" // one frame per field
+)
+
+var deinterlaceModes = []deinterlaceMode{deinterlaceAuto, deinterlaceOn, deinterlaceOff, deinterlaceDouble}
+
+// deinterlacers are the -deinterlacer filters. bwdif is sharper and falls
+// back to yadif on builds without it.
+var deinterlacers = []string{"bwdif", "yadif"}
+
+// deinterlaceSettings control deinterlacing. parity is the field order idet
+// found, "auto" to trust the stream's flags.
+type deinterlaceSettings struct {
+	mode         deinterlaceMode
+	deinterlacer string
+	parity       string
+}
+
+func (d deinterlaceSettings) filter() videoFilter {
+	name := d.deinterlacer
+	if name == "" || !hasFilter(name) {
+		name = "yadif"
+	}
+	rate := "send_frame"
+	if d.mode == deinterlaceDouble {
+		rate = "send_field"
+	}
+	parity := d.parity
+	if parity == "" {
+		parity = "auto"
+	}
+	return newFilter(stageDeinterlace, name, kv("mode", rate), kv("parity", parity), kv("deint", "all"))
+}
+
+// interlacedOrder reports whether an ffprobe field_order says the stream is
+// interlaced; "" and "unknown" say nothing either way.
+func interlacedOrder(fieldOrder string) bool {
+	return slices.Contains([]string{"tt", "bb", "tb", "bt"}, fieldOrder)
+}
+
+// idetSamples and idetSampleLength set how much of the input idet looks at,
+// spread like the crop detection samples so one odd scene doesn't decide.
+const (
+	idetSamples      = 3
+	idetSampleLength = 5.0
+)
+
+var idetRe = regexp.MustCompile(`Multi frame detection:\s*TFF:\s*(\d+)\s*BFF:\s*(\d+)\s*Progressive:\s*(\d+)`)
+
+// detectInterlace runs idet over samples of [start, start+duration) and
+// returns whether most frames are interlaced, and their field order.
+func detectInterlace(file string, video int, start, duration float64, ch chan<- progressMsg, logPath string) (bool, string, error) {
+	if duration <= 0 {
+		return false, "", fmt.Errorf("unknown duration")
+	}
+	length := min(idetSampleLength, duration/idetSamples)
+
+	var tff, bff, progressive int
+	for i := range idetSamples {
+		at := start + duration*(float64(i)+0.5)/idetSamples - length/2
+		args := []string{"-ss", strconv.FormatFloat(at, 'f', 3, 64), "-t", strconv.FormatFloat(length, 'f', 3, 64), "-i", file,
+			"-map", fmt.Sprintf("0:%d", video), "-vf", "idet", "-f", "null", "-"}
+		stderr, err := runFFmpegOutput(args, ch, length, fmt.Sprintf("Interlace Detection (%d/%d)", i+1, idetSamples), logPath)
+		if err != nil {
+			return false, "", err
+		}
+
+		m := idetRe.FindAllStringSubmatch(stderr, -1)
+		if m == nil {
+			continue
+		}
+		last := m[len(m)-1]
+		n := func(i int) int { v, _ := strconv.Atoi(last[i]); return v }
+		tff, bff, progressive = tff+n(1), bff+n(2), progressive+n(3)
+	}
+
+	if tff+bff+progressive == 0 {
+		return false, "", fmt.Errorf("no frames classified")
+	}
+	parity := "tff"
+	if bff > tff {
+		parity = "bff"
+	}
+	return tff+bff > progressive, parity, nil
+}
+
+// resolveDeinterlace settles whether and how the job deinterlaces. In auto
+// mode a stream flagged progressive is taken at its word; anything else is
+// checked with idet, since interlaced captures are often flagged wrong.
+func resolveDeinterlace(opts encodeOptions, info *FFProbeOutput, sel streamSelection, ch chan<- progressMsg, logPath string) deinterlaceSettings {
+	d := opts.deinterlace
+	if d.mode != deinterlaceAuto {
+		return d
+	}
+	d.mode = deinterlaceOff
+	v := info.stream(sel.video)
+	if v == nil || v.FieldOrder == "progressive" {
+		return d
+	}
+
+	ch <- progressMsg{line: "Detecting interlacing..."}
+	start, length := opts.sourceWindow(info)
+	interlaced, parity, err := detectInterlace(opts.inputFile, v.Index, start, length, ch, logPath)
+	switch {
+	case err != nil && interlacedOrder(v.FieldOrder):
+		ch <- progressMsg{kind: evWarning, line: fmt.Sprintf("could not check interlacing (%v), deinterlacing as flagged", err)}
+		d.mode = deinterlaceOn
+	case err != nil:
+		ch <- progressMsg{kind: evWarning, line: fmt.Sprintf("could not check interlacing (%v), leaving deinterlace off", err)}
+	case !interlaced:
+		ch <- progressMsg{line: "Deinterlace off: the video is progressive"}
+	default:
+		d.mode, d.parity = deinterlaceOn, parity
+		ch <- progressMsg{line: fmt.Sprintf("Deinterlace on: the video is interlaced, %s", strings.ToUpper(parity))}
+	}
+	return d
+}
+
+// checksInterlace reports whether the wizard checks the input for
+// interlacing once it's probed: in auto mode, unless the stream is flagged
+// progressive. A join is left to the job, which sees the joined picture.
+func (m model) checksInterlace() bool {
+	if m.deinterlace.mode != deinterlaceAuto || m.outputMode == modeAudio || m.info == nil || len(m.joinFiles) > 1 {
+		return false
+	}
+	v := m.info.stream(m.streams.video)
+	return v != nil && v.FieldOrder != "progressive"
+}
+
+// interlaceDetectedMsg carries the wizard's interlace check of one video
+// stream.
+type interlaceDetectedMsg struct {
+	file       string
+	video      int
+	interlaced bool
+	parity     string
+	err        error
+}
+
+// detectInterlaceCmd runs the interlace check in the background while the
+// wizard's other steps are answered.
+func (m model) detectInterlaceCmd() tea.Cmd {
+	if !m.checksInterlace() {
+		return nil
+	}
+	file, video := m.filePath, m.streams.video
+	duration, _ := strconv.ParseFloat(m.info.Format.Duration, 64)
+	return func() tea.Msg {
+		interlaced, parity, err := detectInterlace(file, video, 0, duration, nil, "")
+		return interlaceDetectedMsg{file: file, video: video, interlaced: interlaced, parity: parity, err: err}
+	}
+}
+
+var (
+	filtersOnce sync.Once
+	filterList  string
+)
+
+// hasFilter reports whether the FFmpeg build has the named filter. The list
+// is read once; if it can't be, every filter is assumed to be there.
+func hasFilter(name string) bool {
+	filtersOnce.Do(func() {
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-filters").Output()
+		if err == nil {
+			filterList = string(out)
+		}
+	})
+	if filterList == "" {
+		return true
+	}
+	for _, line := range strings.Split(filterList, "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[1] == name {
+			return true
+		}
+	}
+	return false
+}
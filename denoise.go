@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// denoisePresets are the -denoise strengths, lightest first.
+var denoisePresets = []string{"light", "medium", "strong"}
+
+// denoisers are the -denoiser filters. hqdn3d is fast; nlmeans keeps more
+// detail at the same strength but is many times slower.
+var denoisers = []string{"hqdn3d", "nlmeans"}
+
+// denoiseLevels hold each preset's settings: hqdn3d's spatial and temporal
+// strengths for luma and chroma, nlmeans' strength, and the film grain level
+// the AV1 encoders resynthesise.
+var denoiseLevels = map[string]struct {
+	hqdn3d  [4]float64
+	nlmeans float64
+	grain   int
+}{
+	"light":  {[4]float64{2, 1.5, 3, 2.25}, 1.5, 4},
+	"medium": {[4]float64{4, 3, 6, 4.5}, 3, 8},
+	"strong": {[4]float64{8, 6, 12, 9}, 6, 15},
+}
+
+// grainEncoders are the encoders that can model the grain they remove and
+// have the decoder add it back.
+var grainEncoders = []string{"libsvtav1", "libaom-av1"}
+
+// denoiseSettings control noise removal before compression. preset is ""
+// for none. With grain set, encoders in grainEncoders remove the noise
+// themselves and store a model of it instead of the filter running.
+type denoiseSettings struct {
+	preset   string
+	denoiser string
+	grain    bool
+}
+
+// synthesizes reports whether encoding with lib resynthesises the grain.
+func (d denoiseSettings) synthesizes(lib string) bool {
+	return d.preset != "" && d.grain && slices.Contains(grainEncoders, lib)
+}
+
+// filter is the denoise filter for the preset, run on the source picture
+// before it's scaled.
+func (d denoiseSettings) filter() videoFilter {
+	level := denoiseLevels[d.preset]
+	if d.denoiser == "nlmeans" {
+		return newFilter(stageDenoise, "nlmeans", kv("s", strconv.FormatFloat(level.nlmeans, 'g', -1, 64)))
+	}
+	args := make([]filterArg, len(level.hqdn3d))
+	for i, v := range level.hqdn3d {
+		args[i] = pos(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return newFilter(stageDenoise, "hqdn3d", args...)
+}
+
+// encoderArgs make lib remove and resynthesise grain, when it does.
+func (d denoiseSettings) encoderArgs(lib string) []string {
+	if !d.synthesizes(lib) {
+		return nil
+	}
+	grain := strconv.Itoa(denoiseLevels[d.preset].grain)
+	if lib == "libaom-av1" {
+		return []string{"-denoise-noise-level", grain}
+	}
+	return []string{"-svtav1-params", "film-grain=" + grain + ":film-grain-denoise=1"}
+}
+
+// label describes the denoise choice for encoding with lib.
+func (d denoiseSettings) label(lib string) string {
+	if d.synthesizes(lib) {
+		return fmt.Sprintf("%s, film grain resynthesised by %s", d.preset, lib)
+	}
+	return fmt.Sprintf("%s (%s)", d.preset, d.filter())
+}
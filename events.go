@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+)
+
+var (
+	errNoInput      = errors.New("no input file given")
+	errOutputExists = errors.New("output file already exists; pass -exists increment, skip or overwrite")
+)
+
+// jsonStream is one probed stream in the "probe" event.
+type jsonStream struct {
+	Index    int    `json:"index"`
+	Type     string `json:"type"`
+	Codec    string `json:"codec,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	BitRate  int64  `json:"bit_rate,omitempty"`
+	Channels int    `json:"channels,omitempty"`
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// jsonEvent is the NDJSON form of a progressMsg or the final workDoneMsg.
+// Fields that don't apply to an event are omitted.
+type jsonEvent struct {
+	Event string `json:"event"`
+
+	Message string   `json:"message,omitempty"`
+	Stage   string   `json:"stage,omitempty"`
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+
+	Percent *float64 `json:"percent,omitempty"`
+	FPS     *float64 `json:"fps,omitempty"`
+	Speed   *float64 `json:"speed,omitempty"`
+	ETA     *float64 `json:"eta,omitempty"`
+	Elapsed *float64 `json:"elapsed,omitempty"`
+
+	Format  string       `json:"format,omitempty"`
+	Streams []jsonStream `json:"streams,omitempty"`
+
+	Output      string      `json:"output,omitempty"`
+	Skipped     string      `json:"skipped,omitempty"`
+	SizeBytes   *int64      `json:"size_bytes,omitempty"`
+	BitrateKbps *float64    `json:"bitrate_kbps,omitempty"`
+	Duration    *float64    `json:"duration,omitempty"`
+	Log         string      `json:"log,omitempty"`
+	Parts       []jsonEvent `json:"parts,omitempty"`
+
+	LoudnessTarget *float64 `json:"loudness_target,omitempty"`
+	LoudnessBefore *float64 `json:"loudness_before,omitempty"`
+	LoudnessAfter  *float64 `json:"loudness_after,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+func (msg progressMsg) jsonEvent() jsonEvent {
+	switch msg.kind {
+	case evProbe:
+		ev := jsonEvent{Event: "probe"}
+		if msg.probe == nil {
+			return ev
+		}
+		duration, _ := strconv.ParseFloat(msg.probe.Format.Duration, 64)
+		size, _ := strconv.ParseInt(msg.probe.Format.Size, 10, 64)
+		ev.Duration = &duration
+		ev.SizeBytes = &size
+		ev.Format = msg.probe.Format.FormatName
+		for _, st := range msg.probe.Streams {
+			bitRate, _ := strconv.ParseInt(st.BitRate, 10, 64)
+			ev.Streams = append(ev.Streams, jsonStream{
+				Index:    st.Index,
+				Type:     st.CodecType,
+				Codec:    st.CodecName,
+				Width:    st.Width,
+				Height:   st.Height,
+				BitRate:  bitRate,
+				Channels: st.Channels,
+				Language: st.language(),
+				Title:    st.Tags["title"],
+			})
+		}
+		return ev
+	case evStageStart:
+		return jsonEvent{Event: "stage_start", Stage: msg.stage}
+	case evStageEnd:
+		return jsonEvent{Event: "stage_end", Stage: msg.stage, Elapsed: &msg.elapsed}
+	case evProgress:
+		percent := msg.progress * 100
+		ev := jsonEvent{Event: "progress", Stage: msg.stage, Percent: &percent, FPS: &msg.fps, Speed: &msg.speed}
+		if msg.eta >= 0 {
+			ev.ETA = &msg.eta
+		}
+		return ev
+	case evCommand:
+		return jsonEvent{Event: "command", Command: msg.debugCmd, Args: msg.args}
+	case evWarning:
+		return jsonEvent{Event: "warning", Message: msg.line}
+	}
+	return jsonEvent{Event: "status", Message: msg.line}
+}
+
+func (msg workDoneMsg) jsonEvent() jsonEvent {
+	ev := jsonEvent{Event: "result", Output: msg.outputFile, Skipped: msg.skipReason, Log: msg.logPath}
+	for _, p := range msg.parts {
+		ev.Parts = append(ev.Parts, p.jsonEvent())
+	}
+	if msg.skipReason != "" {
+		return ev
+	}
+	if msg.err != nil {
+		ev.Error = msg.err.Error()
+		var ffErr *ffmpegError
+		if errors.As(msg.err, &ffErr) {
+			ev.Log = ffErr.LogPath
+		}
+		return ev
+	}
+	ev.SizeBytes = &msg.sizeBytes
+	ev.Duration = &msg.duration
+	if msg.duration > 0 {
+		kbps := float64(msg.sizeBytes) * 8 / msg.duration / 1000
+		ev.BitrateKbps = &kbps
+	}
+	if l := msg.loudness; l != nil {
+		ev.LoudnessTarget = &l.Target
+		ev.LoudnessBefore = &l.Before
+		if !math.IsNaN(l.After) {
+			ev.LoudnessAfter = &l.After
+		}
+	}
+	return ev
+}
+
+// runJSON runs the job described by m's flags without the TUI, writing every
+// progress event and the final result to stdout. It returns the exit code.
+func runJSON(m model) int {
+	enc := json.NewEncoder(os.Stdout)
+	if m.filePath == "" {
+		enc.Encode(workDoneMsg{err: errNoInput}.jsonEvent())
+		return 1
+	}
+	if m.err != nil {
+		enc.Encode(workDoneMsg{err: m.err}.jsonEvent()) // an invalid flag value
+		return 1
+	}
+
+	path, exists := m.naming.resolve(m.encodeOptions())
+	m.outputFile = path
+	if exists {
+		switch m.naming.policy {
+		case collisionSkip:
+			enc.Encode(workDoneMsg{outputFile: path, skipReason: "output already exists"}.jsonEvent())
+			return 0
+		case collisionOverwrite:
+			m.overwrite = true
+		default:
+			enc.Encode(workDoneMsg{outputFile: path, err: errOutputExists}.jsonEvent())
+			return 1
+		}
+	}
+
+	progressChan := make(chan progressMsg)
+	done := make(chan workDoneMsg, 1)
+	go func() {
+		done <- startEncoding(m.encodeOptions(), progressChan)().(workDoneMsg)
+	}()
+
+	for msg := range progressChan {
+		enc.Encode(msg.jsonEvent())
+	}
+
+	res := <-done
+	enc.Encode(res.jsonEvent())
+	if res.err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ffmpegError is a failed FFmpeg or ffprobe call, reduced to a message a
+// user can act on plus the stderr lines that led to it.
+type ffmpegError struct {
+	Stage   string
+	Summary string
+	Lines   []string
+	LogPath string
+	Err     error
+}
+
+func (e *ffmpegError) Error() string {
+	var s strings.Builder
+	s.WriteString(e.Summary)
+	if e.Stage != "" {
+		s.WriteString(fmt.Sprintf(" (%s)", e.Stage))
+	}
+	for _, line := range e.Lines {
+		s.WriteString("\n  " + line)
+	}
+	if e.LogPath != "" {
+		s.WriteString("\nFull log: " + e.LogPath)
+	}
+	return s.String()
+}
+
+func (e *ffmpegError) Unwrap() error {
+	return e.Err
+}
+
+type errorClass struct {
+	pattern *regexp.Regexp
+	summary string
+}
+
+// errorClasses are checked in order; the first one matching any stderr line
+// wins, so more specific patterns go first.
+var errorClasses = []errorClass{
+	{regexp.MustCompile(`(?i)unknown encoder|encoder not found|unrecognized option '(c:v|c:a)'`), "This FFmpeg build doesn't include the selected encoder. Pick another codec or install a full FFmpeg build."},
+	{regexp.MustCompile(`(?i)no such filter|filter not found`), "This FFmpeg build is missing a required filter. Install a full FFmpeg build."},
+	{regexp.MustCompile(`(?i)cannot load (nvcuda|libcuda|libnvidia)|no nvenc capable devices|amfrt64\.dll|failed to initialise amf|failed to create .*device|error creating a mfx session|device creation failed`), "The hardware encoder isn't available on this machine. Choose CPU encoding or another GPU vendor."},
+	{regexp.MustCompile(`(?i)invalid dimensions|not divisible by|picture size .* is invalid|width .* must be|height .* must be|invalid frame size`), "The output resolution isn't valid for this encoder. Use even dimensions, e.g. 1280x720."},
+	{regexp.MustCompile(`(?i)permission denied|operation not permitted|access is denied`), "Permission denied. Check that the output folder is writable and the input is readable."},
+	{regexp.MustCompile(`(?i)no space left on device|disk full|not enough space`), "The disk is full. Free up space or choose another output folder."},
+	{regexp.MustCompile(`(?i)no such file or directory`), "A file couldn't be found. Check the input and output paths."},
+	{regexp.MustCompile(`(?i)invalid data found when processing input|moov atom not found|error while decoding|corrupt|truncat|could not find codec parameters`), "The input file looks corrupt or isn't a supported media file."},
+}
+
+// newFFmpegError classifies a failed command from its stderr output.
+func newFFmpegError(stage string, err error, stderr, logPath string) error {
+	fe := &ffmpegError{Stage: stage, LogPath: logPath, Err: err}
+
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		fe.Summary = fmt.Sprintf("%s was not found. Install FFmpeg and make sure it is in your PATH.", execErr.Name)
+		return fe
+	}
+
+	lines := stderrLines(stderr)
+	for _, class := range errorClasses {
+		for _, line := range lines {
+			if class.pattern.MatchString(line) {
+				fe.Summary = class.summary
+				fe.Lines = matchingLines(lines, class.pattern)
+				return fe
+			}
+		}
+	}
+
+	fe.Summary = fmt.Sprintf("FFmpeg failed: %v", err)
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+	fe.Lines = lines
+	return fe
+}
+
+func stderrLines(stderr string) []string {
+	var lines []string
+	for _, line := range strings.Split(stderr, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// matchingLines returns up to 3 lines matching re, plus the final line
+// FFmpeg printed, which usually names the stage that gave up.
+func matchingLines(lines []string, re *regexp.Regexp) []string {
+	var out []string
+	for _, line := range lines {
+		if re.MatchString(line) && len(out) < 3 {
+			out = append(out, line)
+		}
+	}
+	if last := lines[len(lines)-1]; out[len(out)-1] != last {
+		out = append(out, last)
+	}
+	return out
+}
+
+// newJobLog returns the path of a fresh log file for one job, or "" if the
+// log directory can't be created.
+func newJobLog(inputFile string) string {
+	dir := filepath.Join(os.TempDir(), "teacrush")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ""
+	}
+	name := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.log", name, time.Now().Format("20060102-150405.000")))
+}
+
+// appendJobLog records one call of tool (ffmpeg or ffprobe) and its complete
+// stderr.
+func appendJobLog(logPath, tool string, args []string, stderr string) {
+	if logPath == "" {
+		return
+	}
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "$ %s %s\n%s\n", tool, strings.Join(args, " "), stderr)
+}
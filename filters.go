@@ -0,0 +1,216 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// filterStage orders the filters of a pipeline. Filters run stage by stage,
+// and in the order they were added within a stage.
+type filterStage int
+
+const (
+	stageCut         filterStage = iota // cut list gaps and fades, on source timestamps
+	stageDeinterlace                    // before anything that moves or resizes the fields
+	stageCrop
+	stageStabilize
+	stageDenoise
+	stageScale
+	stageFPS
+	stageOverlay // drawn onto the final picture, e.g. burned-in subtitles
+	stageDedupe  // after the overlays, so frames that only differ there are kept
+	stageExtra   // user-supplied -vf filters
+	stageFormat
+)
+
+// filterArg is one option of a filter; key is empty for positional options.
+type filterArg struct {
+	key, value string
+}
+
+// videoFilter is one filter of a pipeline. raw filters are passed through as
+// written and may hold a whole chain.
+type videoFilter struct {
+	stage filterStage
+	name  string
+	args  []filterArg
+	raw   string
+}
+
+func newFilter(stage filterStage, name string, args ...filterArg) videoFilter {
+	return videoFilter{stage: stage, name: name, args: args}
+}
+
+// pos and kv build filter options.
+func pos(value string) filterArg     { return filterArg{value: value} }
+func kv(key, value string) filterArg { return filterArg{key: key, value: value} }
+
+func (f videoFilter) String() string {
+	if f.raw != "" {
+		return f.raw
+	}
+	if len(f.args) == 0 {
+		return f.name
+	}
+	opts := make([]string, len(f.args))
+	for i, a := range f.args {
+		opts[i] = filterEscape(a.value)
+		if a.key != "" {
+			opts[i] = a.key + "=" + opts[i]
+		}
+	}
+	return f.name + "=" + strings.Join(opts, ":")
+}
+
+// filterPipeline is the video filter chain shared by every output mode.
+type filterPipeline struct {
+	filters []videoFilter
+}
+
+func (p *filterPipeline) add(filters ...videoFilter) {
+	p.filters = append(p.filters, filters...)
+}
+
+// addRaw adds user-supplied filters at stage as they were written.
+func (p *filterPipeline) addRaw(stage filterStage, chain string) {
+	if chain = strings.TrimSpace(chain); chain != "" {
+		p.filters = append(p.filters, videoFilter{stage: stage, raw: chain})
+	}
+}
+
+// with returns a copy of p with filters added, leaving p alone.
+func (p filterPipeline) with(filters ...videoFilter) filterPipeline {
+	p.filters = append(slices.Clone(p.filters), filters...)
+	return p
+}
+
+// before returns a copy of p holding only the filters of stages before
+// stage, for analysis passes that need the picture as it reaches stage.
+func (p filterPipeline) before(stage filterStage) filterPipeline {
+	var kept []videoFilter
+	for _, f := range p.filters {
+		if f.stage < stage {
+			kept = append(kept, f)
+		}
+	}
+	return filterPipeline{kept}
+}
+
+func (p filterPipeline) empty() bool {
+	return len(p.filters) == 0
+}
+
+// chain renders the filters in stage order, for -vf.
+func (p filterPipeline) chain() string {
+	sorted := slices.Clone(p.filters)
+	slices.SortStableFunc(sorted, func(a, b videoFilter) int { return cmp.Compare(a.stage, b.stage) })
+	parts := make([]string, len(sorted))
+	for i, f := range sorted {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// vfArgs returns the -vf arguments for p, or nothing if it's empty.
+func (p filterPipeline) vfArgs() []string {
+	if p.empty() {
+		return nil
+	}
+	return []string{"-vf", p.chain()}
+}
+
+// labelled renders p as one chain of a -lavfi graph, reading pad in and
+// writing pad out.
+func (p filterPipeline) labelled(in, out string) string {
+	chain := p.chain()
+	if chain == "" {
+		chain = "null"
+	}
+	return fmt.Sprintf("[%s]%s[%s]", in, chain, out)
+}
+
+// lavfiArgs joins graph chains into a -lavfi argument for graphs with
+// several inputs.
+func lavfiArgs(chains ...string) []string {
+	return []string{"-lavfi", strings.Join(chains, ";")}
+}
+
+// videoPipeline builds the filters a job applies to its video, whatever the
+// output mode.
+func videoPipeline(opts encodeOptions, info *FFProbeOutput) (filterPipeline, error) {
+	var p filterPipeline
+	edit, _ := opts.editFilters(info)
+	p.add(edit...)
+	var w, h int // size of the picture going into the scale stage
+	if opts.streams != nil {
+		if v := info.stream(opts.streams.video); v != nil {
+			w, h = v.Width, v.Height
+		}
+	}
+	if d := opts.deinterlace; d.mode == deinterlaceOn || d.mode == deinterlaceDouble {
+		p.add(d.filter())
+	}
+	if d := opts.denoise; d.preset != "" && !d.synthesizes(opts.codecCfg.FFmpegLib) {
+		p.add(d.filter())
+	}
+	if opts.crop != "" {
+		crop, err := parseCrop(opts.crop, w, h)
+		if err != nil {
+			return p, err
+		}
+		if crop != nil {
+			p.add(crop.filter())
+			w, h = crop.W, crop.H
+		}
+	}
+	if opts.stabilize != nil {
+		p.add(opts.stabilize.filters(w, h)...)
+	}
+	if opts.reframe != nil {
+		reframe, err := opts.reframe.filters(opts.resInput, w, h)
+		if err != nil {
+			return p, err
+		}
+		p.add(reframe...)
+	} else if scale := buildScaleFilter(opts.resInput); scale != nil {
+		p.add(*scale)
+	}
+	if speed := opts.speedFactor(); speed != 1 {
+		var v *probeStream
+		if opts.streams != nil {
+			v = info.stream(opts.streams.video)
+		}
+		p.add(speedFilters(speed, v, opts.fpsInput)...)
+	}
+	if opts.dedupe.mode == dedupeOn {
+		p.add(opts.dedupe.filter())
+	}
+	if opts.fpsInput != "" {
+		p.add(newFilter(stageFPS, "fps", pos(opts.fpsInput)))
+	}
+	if opts.burn != nil {
+		burn, err := opts.burn.filters(opts.inputFile, info, timeMap{opts.cuts, opts.speedFactor()})
+		if err != nil {
+			return p, err
+		}
+		p.add(burn...)
+	}
+	p.addRaw(stageExtra, opts.extraFilters)
+	return p, nil
+}
+
+// filterEscape escapes a filter option value: once for the option parser,
+// once for the graph parser.
+func filterEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(s)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `,`, `\,`, `;`, `\;`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
+
+// filterPath is a file path as a filter option value. FFmpeg takes forward
+// slashes on every platform, which avoids a layer of backslashes.
+func filterPath(path string) string {
+	return filepath.ToSlash(path)
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestFilterEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"subs.srt", "subs.srt"},
+		{"a:b", `a\\:b`},
+		{"it's", `it\\\'s`},
+		{`C:\subs`, `C\\:\\\\subs`},
+		{"a,b;c[d]", `a\,b\;c\[d\]`},
+	}
+	for _, tt := range tests {
+		if got := filterEscape(tt.in); got != tt.want {
+			t.Errorf("filterEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
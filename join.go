@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// joinFormat is the common format every input of a join is converted to
+// before they are concatenated. The first input sets it.
+type joinFormat struct {
+	w, h       int
+	fps        string // ffprobe rate, e.g. "30000/1001"
+	sampleRate int
+	fit        *reframeSettings // how other shapes are fitted to w x h
+	audio      bool             // false when no input has audio
+}
+
+// joinPixFmt is the pixel format inputs are converted to, which every
+// encoder the joined file is compressed with accepts.
+const joinPixFmt = "yuv420p"
+
+// displaySize is the stream's size as played, after the rotation phones
+// record in metadata instead of turning the picture.
+func (st probeStream) displaySize() (int, int) {
+	rotation, _ := strconv.ParseFloat(st.Tags["rotate"], 64)
+	for _, sd := range st.SideDataList {
+		if sd.Rotation != 0 {
+			rotation = sd.Rotation
+		}
+	}
+	if int(math.Abs(rotation))%180 == 90 {
+		return st.Height, st.Width
+	}
+	return st.Width, st.Height
+}
+
+// probeJoin probes every input of a join. Failures go to logPath, if set.
+func probeJoin(files []string, logPath string) ([]*FFProbeOutput, error) {
+	infos := make([]*FFProbeOutput, len(files))
+	for i, f := range files {
+		info, err := probeFile(f, logPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(f), err)
+		}
+		if info.firstStream("video") == nil {
+			return nil, fmt.Errorf("%s has no video stream to join", filepath.Base(f))
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
+
+// newJoinFormat picks the common format for infos. Inputs of another shape
+// are fitted with reframe's mode when one was asked for, which also sets the
+// frame's aspect ratio; otherwise they are padded to the first input's.
+func newJoinFormat(infos []*FFProbeOutput, reframe *reframeSettings) joinFormat {
+	v := infos[0].firstStream("video")
+	w, h := v.displaySize()
+	f := joinFormat{fps: v.AvgFrameRate, sampleRate: 48000}
+	if v.frameRate() <= 0 {
+		f.fps = "30"
+	}
+
+	if reframe != nil {
+		fit := *reframe
+		f.fit = &fit
+		w, h = reframe.baseSize(w, h)
+	} else {
+		f.fit = &reframeSettings{aspectW: w, aspectH: h, mode: "pad", offset: 0.5, color: "black"}
+	}
+	f.w, f.h = w&^1, h&^1
+	f.fit.aspectW, f.fit.aspectH = f.w, f.h
+
+	for _, info := range infos {
+		if a := info.firstStream("audio"); a != nil {
+			if !f.audio {
+				if rate, _ := strconv.Atoi(a.SampleRate); rate > 0 {
+					f.sampleRate = rate
+				}
+			}
+			f.audio = true
+		}
+	}
+	return f
+}
+
+// joinedInfo describes the file a join produces, so the wizard and the
+// size budget see one input of the summed duration.
+func joinedInfo(infos []*FFProbeOutput, f joinFormat) *FFProbeOutput {
+	out := &FFProbeOutput{}
+	out.Format.FormatName = "matroska"
+	var duration, size float64
+	for _, info := range infos {
+		d, _ := strconv.ParseFloat(info.Format.Duration, 64)
+		s, _ := strconv.ParseFloat(info.Format.Size, 64)
+		duration, size = duration+d, size+s
+	}
+	out.Format.Duration = strconv.FormatFloat(duration, 'f', 3, 64)
+	out.Format.Size = strconv.FormatFloat(size, 'f', 0, 64)
+	if duration > 0 {
+		out.Format.BitRate = strconv.FormatFloat(size*8/duration, 'f', 0, 64)
+	}
+
+	out.Streams = append(out.Streams, probeStream{Index: 0, CodecType: "video", CodecName: "h264", Width: f.w, Height: f.h, AvgFrameRate: f.fps})
+	if f.audio {
+		out.Streams = append(out.Streams, probeStream{Index: 1, CodecType: "audio", CodecName: "flac", Channels: 2, SampleRate: strconv.Itoa(f.sampleRate)})
+	}
+	return out
+}
+
+// joinGraph converts each input to f and concatenates them, writing [v] and,
+// if f has audio, [a]. Inputs without audio get silence of their length.
+func joinGraph(infos []*FFProbeOutput, f joinFormat) (string, error) {
+	var chains []string
+	var pads strings.Builder
+	for i, info := range infos {
+		v := info.firstStream("video")
+		w, h := v.displaySize()
+		fit, err := f.fit.filters(fmt.Sprintf("%dx%d", f.w, f.h), w, h)
+		if err != nil {
+			return "", fmt.Errorf("input %d: %w", i+1, err)
+		}
+		p := filterPipeline{fit}
+		p.add(newFilter(stageFPS, "fps", pos(f.fps)), newFilter(stageFormat, "format", pos(joinPixFmt)))
+		// the blur fit uses fixed pad names, which must be unique in the graph
+		chain := strings.ReplaceAll(p.labelled(fmt.Sprintf("%d:%d", i, v.Index), fmt.Sprintf("v%d", i)), "[rf_", fmt.Sprintf("[rf%d_", i))
+		chains = append(chains, chain)
+		fmt.Fprintf(&pads, "[v%d]", i)
+
+		if !f.audio {
+			continue
+		}
+		layout := newFilter(stageFormat, "aformat", kv("sample_fmts", "fltp"), kv("sample_rates", strconv.Itoa(f.sampleRate)), kv("channel_layouts", "stereo"))
+		if a := info.firstStream("audio"); a != nil {
+			resample := newFilter(stageFormat, "aresample", pos(strconv.Itoa(f.sampleRate)))
+			chains = append(chains, filterPipeline{[]videoFilter{resample, layout}}.labelled(fmt.Sprintf("%d:%d", i, a.Index), fmt.Sprintf("a%d", i)))
+		} else {
+			d, _ := strconv.ParseFloat(info.Format.Duration, 64)
+			silence := []videoFilter{
+				newFilter(stageFormat, "anullsrc", kv("r", strconv.Itoa(f.sampleRate)), kv("cl", "stereo")),
+				newFilter(stageFormat, "atrim", kv("duration", strconv.FormatFloat(d, 'f', 3, 64))),
+				layout,
+			}
+			chains = append(chains, filterPipeline{silence}.chain()+fmt.Sprintf("[a%d]", i))
+		}
+		fmt.Fprintf(&pads, "[a%d]", i)
+	}
+
+	concat := fmt.Sprintf("%sconcat=n=%d:v=1:a=0[v]", pads.String(), len(infos))
+	if f.audio {
+		concat = fmt.Sprintf("%sconcat=n=%d:v=1:a=1[v][a]", pads.String(), len(infos))
+	}
+	return strings.Join(append(chains, concat), ";"), nil
+}
+
+// joinInputs concatenates the job's inputs into one lossless intermediate
+// file, which the job then compresses like any other input. The caller
+// removes it.
+func joinInputs(opts encodeOptions, ch chan<- progressMsg, logPath string) (string, error) {
+	infos, err := probeJoin(opts.joinFiles, logPath)
+	if err != nil {
+		return "", err
+	}
+	f := newJoinFormat(infos, opts.reframe)
+	graph, err := joinGraph(infos, f)
+	if err != nil {
+		return "", err
+	}
+	ch <- progressMsg{line: fmt.Sprintf("Joining %d files at %dx%d, %s fps", len(infos), f.w, f.h, f.fps)}
+
+	out := filepath.Join(os.TempDir(), fmt.Sprintf("join_%d.mkv", time.Now().UnixNano()))
+	args := []string{"-y"}
+	for _, file := range opts.joinFiles {
+		args = append(args, "-i", file)
+	}
+	args = append(args, "-filter_complex", graph, "-map", "[v]", "-c:v", "libx264", "-qp", "0", "-preset", "ultrafast")
+	if f.audio {
+		args = append(args, "-map", "[a]", "-c:a", "flac")
+	}
+	args = append(args, "-f", "matroska", out)
+
+	duration, _ := strconv.ParseFloat(joinedInfo(infos, f).Format.Duration, 64)
+	if err := runFFmpeg(args, ch, duration, "Joining", logPath); err != nil {
+		os.Remove(out)
+		return "", err
+	}
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// loudnormPreset is an EBU R128 target: integrated loudness (LUFS), true
+// peak (dBTP) and loudness range (LU).
+type loudnormPreset struct {
+	I, TP, LRA float64
+}
+
+var loudnormPresets = map[string]loudnormPreset{
+	"streaming": {-14, -1, 11},
+	"podcast":   {-16, -1.5, 11},
+	"broadcast": {-23, -1, 7},
+}
+
+// loudnessStats is what loudnorm reports after a measuring pass.
+type loudnessStats struct {
+	I, TP, LRA, Thresh, Offset float64
+}
+
+// loudnessResult is shown in the final summary.
+type loudnessResult struct {
+	Target float64
+	Before float64
+	After  float64
+}
+
+func (p loudnormPreset) params() string {
+	return fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g", p.I, p.TP, p.LRA)
+}
+
+// filter applies p using the values measured in the first pass. loudnorm
+// works at 192 kHz internally, so the output is brought back to 48 kHz.
+func (p loudnormPreset) filter(m *loudnessStats) string {
+	return fmt.Sprintf("%s:measured_I=%.2f:measured_TP=%.2f:measured_LRA=%.2f:measured_thresh=%.2f:offset=%.2f:linear=true,aresample=48000",
+		p.params(), m.I, m.TP, m.LRA, m.Thresh, m.Offset)
+}
+
+func formatLUFS(v float64) string {
+	if math.IsNaN(v) {
+		return "?"
+	}
+	return fmt.Sprintf("%.1f LUFS", v)
+}
+
+// measureLoudness runs a loudnorm analysis pass over the audio of file,
+// limited to one track by mapArgs if given.
+func measureLoudness(file string, trimArgs, mapArgs []string, p loudnormPreset, ch chan<- progressMsg, duration float64, stage, logPath string) (*loudnessStats, error) {
+	args := []string{"-y"}
+	args = append(args, trimArgs...)
+	args = append(args, "-i", file)
+	if mapArgs != nil {
+		args = append(args, mapArgs...)
+	} else {
+		args = append(args, "-vn", "-sn")
+	}
+	args = append(args, "-af", p.params()+":print_format=json", "-f", "null", "-")
+
+	stderr, err := runFFmpegOutput(args, ch, duration, stage, logPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseLoudnorm(stderr)
+}
+
+// parseLoudnorm reads the JSON block loudnorm prints at the end of stderr.
+func parseLoudnorm(stderr string) (*loudnessStats, error) {
+	start := strings.LastIndex(stderr, "{")
+	end := strings.LastIndex(stderr, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("loudnorm printed no measurements")
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(stderr[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("could not read loudnorm measurements: %w", err)
+	}
+	num := func(key string) float64 {
+		v, _ := strconv.ParseFloat(raw[key], 64)
+		return v
+	}
+	return &loudnessStats{
+		I:      num("input_i"),
+		TP:     num("input_tp"),
+		LRA:    num("input_lra"),
+		Thresh: num("input_thresh"),
+		Offset: num("target_offset"),
+	}, nil
+}
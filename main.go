@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -51,6 +52,10 @@ type state int
 
 const (
 	stateInputFile state = iota
+	stateProbing
+	stateSelectStreams
+	stateInputCuts
+	stateSelectCrop
 	stateInputSize
 	stateInputRes
 	stateFPS
@@ -58,6 +63,8 @@ const (
 	stateSelectCodec
 	stateSelectCRF
 	stateSelectQuality
+	stateConfirmPassthrough
+	stateConfirmOverwrite
 	stateProcessing
 	stateDone
 	stateError
@@ -107,15 +114,44 @@ var encoderMap = map[hwType][]codecInfo{
 	},
 }
 
+type eventKind int
+
+const (
+	evStatus eventKind = iota
+	evProbe
+	evStageStart
+	evStageEnd
+	evProgress
+	evCommand
+	evWarning
+)
+
+// progressMsg is a single event from a running job. The TUI renders line,
+// progress and debugCmd; -json mode writes every event as one NDJSON line.
 type progressMsg struct {
+	kind     eventKind
 	line     string
 	progress float64
 	debugCmd string
+	args     []string
+
+	stage   string
+	fps     float64
+	speed   float64
+	eta     float64 // seconds, -1 if unknown
+	elapsed float64 // seconds, evStageEnd only
+	probe   *FFProbeOutput
 }
 
 type workDoneMsg struct {
+	skipReason string // non-empty if the job produced no output on purpose
 	outputFile string
 	finalSize  string
+	sizeBytes  int64
+	duration   float64
+	logPath    string
+	loudness   *loudnessResult
+	parts      []workDoneMsg // each output of a split job
 	err        error
 }
 
@@ -126,6 +162,7 @@ const (
 	modeGIF
 	modeAPNG
 	modeAVIF
+	modeAudio
 )
 
 type model struct {
@@ -134,17 +171,58 @@ type model struct {
 	spinner   spinner.Model
 	err       error
 
-	outputMode outputMode
-	verbose    bool
-	customOut  string
+	outputMode  outputMode
+	verbose     bool
+	jsonMode    bool
+	customOut   string
+	container   string // empty = from -o or the codec
+	naming      outputNaming
+	split       *splitSettings
+	parts       []workDoneMsg
+	overwrite   bool
+	passthrough passthroughPolicy
+	audio       audioSettings
+	cover       bool
+	loudnorm    string
+	info        *FFProbeOutput
+
+	streams      streamSelection
+	streamIdx    []int    // -streams
+	audioLangs   []string // -alang
+	subLangs     []string // -slang
+	burn         *burnIn  // -burn, or picked in the stream step
+	extraVf      string   // -vf
+	reframe      *reframeSettings
+	speed        float64 // -speed, 1 = unchanged
+	dedupe       dedupeSettings
+	deinterlace  deinterlaceSettings
+	interlacing  string // what the wizard's interlace check found, "" until it's done
+	denoise      denoiseSettings
+	stabilize    *stabilizeSettings
+	fpsMode      string // -fps-mode: cfr, vfr or "" for auto
+	streamCursor int
+
+	crop          string // -crop, or entered in the crop step
+	cropFlag      bool
+	cropDetecting bool
+	cropDetected  *cropRect
+	cropNote      string
 
 	filePath      string
+	join          bool
+	joinFiles     []string // -join: every input, in order; filePath is the first
 	originalSize  float64
 	targetSizeMB  float64
 	targetRes     string
-	targetFPS     string // empty = real
-	trimStart     string
-	trimEnd       string
+	targetFPS     string      // empty = real
+	cutSpec       string      // -trim, -cut or -cuts, resolved once the file is probed
+	cuts          []keepRange // nil = the whole file
+	cutsFlag      bool
+	snap          bool // snap cut points to keyframes
+	fade          float64
+	deadAir       string // -deadair: "", "ends" or "all"; cleared once the trim step proposed it
+	deadAirBusy   bool
+	deadAirNote   string
 	selectedHW    int
 	selectedCodec int
 	crfLevel      int // 0 to 10
@@ -154,8 +232,13 @@ type model struct {
 	currentLog   string
 	currentCmd   string
 	percent      float64
+	warnings     []string
 	outputFile   string
 	finalSize    string
+	logPath      string
+	loudness     *loudnessResult
+	skipReason   string
+	passReason   string
 
 	suggestions   []string
 	suggestionIdx int
@@ -178,67 +261,697 @@ func initialModel(mode outputMode) model {
 		crfLevel:     5, // medium/balanced quality
 		qualityLevel: 2, // balanced speed
 		outputMode:   mode,
+		naming:       outputNaming{template: defaultNameTemplate, policy: collisionAsk},
+		passthrough:  passAsk,
+		dedupe:       dedupeSettings{mode: dedupeAuto},
+		deinterlace:  deinterlaceSettings{mode: deinterlaceAuto, deinterlacer: "bwdif"},
+		denoise:      denoiseSettings{denoiser: "hqdn3d"},
+		speed:        1,
 	}
 
 	args := os.Args[1:]
 	skip := 0
+	var inputs []string
 	for i, arg := range args {
 		if skip > 0 {
 			skip--
 			continue
 		}
-		if arg == "-gif" || arg == "-apng" || arg == "-avif" {
+		if arg == "-gif" || arg == "-apng" || arg == "-avif" || arg == "-audio" {
+			continue
+		}
+		if (arg == "-split-size" || arg == "-split-time" || arg == "-split-at") && i+1 < len(args) {
+			if m.split == nil {
+				m.split = &splitSettings{at: "keyframe"}
+			}
+			var err error
+			switch arg {
+			case "-split-size":
+				m.split.sizeMB, err = strconv.ParseFloat(strings.TrimSuffix(strings.ToUpper(args[i+1]), "MB"), 64)
+				if err != nil || m.split.sizeMB <= 0 {
+					err = fmt.Errorf("invalid part size %q: use MB, e.g. 25", args[i+1])
+				}
+			case "-split-time":
+				m.split.seconds, err = parseDuration(args[i+1])
+				if err == nil && m.split.seconds <= 0 {
+					err = fmt.Errorf("invalid part length %q", args[i+1])
+				}
+			case "-split-at":
+				m.split.at = strings.ToLower(args[i+1])
+				if !slices.Contains(splitPoints, m.split.at) {
+					err = fmt.Errorf("invalid -split-at %q: use keyframe or scene", args[i+1])
+				}
+			}
+			if err != nil {
+				m.err = err
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-chapters" {
+			if m.split == nil {
+				m.split = &splitSettings{at: "keyframe"}
+			}
+			m.split.by = "chapters"
+			continue
+		}
+		if arg == "-scenes" && i+1 < len(args) {
+			if m.split == nil {
+				m.split = &splitSettings{at: "keyframe"}
+			}
+			m.split.by = "scenes"
+			var err error
+			if m.split.minScene, err = parseDuration(args[i+1]); err != nil {
+				m.err = err
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-join" {
+			m.join = true
+			continue
+		}
+		if arg == "-cover" {
+			m.cover = true
+			continue
+		}
+		if arg == "-loudnorm" && i+1 < len(args) {
+			if _, ok := loudnormPresets[args[i+1]]; ok {
+				m.loudnorm = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid -loudnorm %q: use streaming, podcast or broadcast", args[i+1])
+			}
+			skip = 1
 			continue
 		}
 		if arg == "-v" {
 			m.verbose = true
 			continue
 		}
+		if arg == "-json" || arg == "--json" {
+			m.jsonMode = true
+			continue
+		}
+		if arg == "-size" && i+1 < len(args) {
+			if size, err := strconv.ParseFloat(args[i+1], 64); err == nil && size > 0 {
+				m.targetSizeMB = size
+			} else {
+				m.err = fmt.Errorf("invalid -size %q: use a size in MB, e.g. 10", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-res" && i+1 < len(args) {
+			if validResolution(args[i+1]) {
+				m.targetRes = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid -res %q: use a divisor such as 2, or a size such as 1280x720", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-fps" && i+1 < len(args) {
+			if validFrameRate(args[i+1]) {
+				m.targetFPS = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid -fps %q: use a frame rate such as 30 or 30000/1001", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-codec" && i+1 < len(args) {
+			if hwIdx, codecIdx, ok := findCodec(args[i+1], m.outputMode); ok {
+				m.selectedHW = hwIdx
+				m.selectedCodec = codecIdx
+			} else {
+				m.err = fmt.Errorf("unknown encoder %q for this mode: use an FFmpeg encoder name such as libx264 or hevc_nvenc", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-crf" && i+1 < len(args) {
+			if v, err := strconv.Atoi(args[i+1]); err == nil && v >= 0 && v <= 10 {
+				m.crfLevel = v
+			} else {
+				m.err = fmt.Errorf("invalid -crf %q: use 0 to 10", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-preset" && i+1 < len(args) {
+			if v, err := strconv.Atoi(args[i+1]); err == nil && v >= 0 && v <= 4 {
+				m.qualityLevel = v
+			} else {
+				m.err = fmt.Errorf("invalid -preset %q: use 0 (fastest) to 4", args[i+1])
+			}
+			skip = 1
+			continue
+		}
 		if arg == "-o" {
 			if i+1 < len(args) {
 				m.customOut = args[i+1]
+				m.naming.custom = args[i+1]
 				skip = 1
 				continue
 			}
 		}
+		if arg == "-container" && i+1 < len(args) {
+			if _, ok := containers[args[i+1]]; ok {
+				m.container = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid -container %q: use mp4, mov, webm or mkv", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-passthrough" && i+1 < len(args) {
+			if p := passthroughPolicy(args[i+1]); slices.Contains(passthroughPolicies, p) {
+				m.passthrough = p
+			} else {
+				m.err = fmt.Errorf("invalid -passthrough %q: use ask, copy, skip or encode", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-acodec" && i+1 < len(args) {
+			if _, ok := audioEncoders[args[i+1]]; ok {
+				m.audio.codec = args[i+1]
+			} else if args[i+1] == "auto" {
+				m.audio.codec = ""
+			} else {
+				m.err = fmt.Errorf("invalid -acodec %q: use auto, aac, opus, mp3, vorbis, flac, ac3 or alac", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-ab" && i+1 < len(args) {
+			if v, err := strconv.Atoi(strings.TrimSuffix(args[i+1], "k")); err == nil && v > 0 {
+				m.audio.kbit = v
+			} else if args[i+1] == "auto" {
+				m.audio.kbit = 0
+			} else {
+				m.err = fmt.Errorf("invalid -ab %q: use a bitrate in kbit/s such as 128, or auto", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-ac" && i+1 < len(args) {
+			if m.audio.channels = parseChannels(args[i+1]); m.audio.channels == 0 {
+				m.err = fmt.Errorf("invalid -ac %q: use mono, stereo or 1 to 8 channels", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-ar" && i+1 < len(args) {
+			if v, err := strconv.Atoi(args[i+1]); err == nil && v > 0 {
+				m.audio.sampleRate = v
+			} else {
+				m.err = fmt.Errorf("invalid -ar %q: use a sample rate in Hz, e.g. 48000", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-mute" {
+			m.audio.mute = true
+			continue
+		}
+		if arg == "-streams" && i+1 < len(args) {
+			if idx, err := parseIndexList(args[i+1]); err == nil {
+				m.streamIdx = idx
+			} else {
+				m.err = err
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-alang" && i+1 < len(args) {
+			m.audioLangs = strings.Split(args[i+1], ",")
+			skip = 1
+			continue
+		}
+		if arg == "-dedupe" && i+1 < len(args) {
+			if d := dedupeMode(args[i+1]); slices.Contains(dedupeModes, d) {
+				m.dedupe.mode = d
+			} else {
+				m.err = fmt.Errorf("invalid -dedupe %q: use auto, on or off", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-deinterlace" && i+1 < len(args) {
+			if d := deinterlaceMode(args[i+1]); slices.Contains(deinterlaceModes, d) {
+				m.deinterlace.mode = d
+			} else {
+				m.err = fmt.Errorf("invalid -deinterlace %q: use auto, on, off or double", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-deinterlacer" && i+1 < len(args) {
+			if slices.Contains(deinterlacers, args[i+1]) {
+				m.deinterlace.deinterlacer = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid deinterlacer %q: use bwdif or yadif", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-denoise" && i+1 < len(args) {
+			if slices.Contains(denoisePresets, args[i+1]) {
+				m.denoise.preset = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid -denoise %q: use light, medium or strong", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-denoiser" && i+1 < len(args) {
+			if slices.Contains(denoisers, args[i+1]) {
+				m.denoise.denoiser = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid denoiser %q: use hqdn3d or nlmeans", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-grain" {
+			m.denoise.grain = true
+			continue
+		}
+		if arg == "-stabilize" && i+1 < len(args) {
+			if m.stabilize == nil {
+				m.stabilize = &stabilizeSettings{zoom: -1}
+			}
+			if slices.Contains(stabilizePresets, args[i+1]) {
+				m.stabilize.strength = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid -stabilize %q: use light, medium or strong", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-stab-zoom" && i+1 < len(args) {
+			if m.stabilize == nil {
+				m.stabilize = &stabilizeSettings{zoom: -1}
+			}
+			if z, err := strconv.ParseFloat(strings.TrimSuffix(args[i+1], "%"), 64); err == nil && z >= 0 && z <= 100 {
+				m.stabilize.zoom = z
+			} else if args[i+1] != "auto" {
+				m.err = fmt.Errorf("invalid -stab-zoom %q: use auto or a percentage from 0 to 100", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-dedupe-thresh" && i+1 < len(args) {
+			if d, err := m.dedupe.parseThresholds(args[i+1]); err == nil {
+				m.dedupe = d
+			} else {
+				m.err = err
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-fps-mode" && i+1 < len(args) {
+			if args[i+1] == "cfr" || args[i+1] == "vfr" {
+				m.fpsMode = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid -fps-mode %q: use cfr or vfr", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-speed" && i+1 < len(args) {
+			if speed, err := parseSpeed(args[i+1]); err == nil {
+				m.speed = speed
+			} else {
+				m.err = err
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-reframe" && i+1 < len(args) {
+			if r, err := parseReframe(args[i+1]); err == nil {
+				m.reframe = r
+			} else {
+				m.err = err
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-crop" && i+1 < len(args) {
+			if c := strings.ToLower(args[i+1]); c != "none" && c != "off" {
+				m.crop = args[i+1]
+			}
+			m.cropFlag = true
+			skip = 1
+			continue
+		}
+		if arg == "-vf" && i+1 < len(args) {
+			m.extraVf = args[i+1]
+			skip = 1
+			continue
+		}
+		if arg == "-burn" && i+1 < len(args) {
+			m.burn = parseBurn(args[i+1])
+			skip = 1
+			continue
+		}
+		if arg == "-slang" && i+1 < len(args) {
+			m.subLangs = strings.Split(args[i+1], ",")
+			skip = 1
+			continue
+		}
+		if arg == "-name" && i+1 < len(args) {
+			m.naming.template = args[i+1]
+			skip = 1
+			continue
+		}
+		if arg == "-outdir" && i+1 < len(args) {
+			m.naming.dir = cleanPath(args[i+1])
+			skip = 1
+			continue
+		}
+		if arg == "-exists" && i+1 < len(args) {
+			if p := collisionPolicy(args[i+1]); slices.Contains(collisionPolicies, p) {
+				m.naming.policy = p
+			} else {
+				m.err = fmt.Errorf("invalid -exists %q: use ask, increment, skip or overwrite", args[i+1])
+			}
+			skip = 1
+			continue
+		}
 		if arg == "-trim" {
 			if i+2 < len(args) {
-				m.trimStart = args[i+1]
-				m.trimEnd = args[i+2]
+				m.cutSpec = args[i+1] + " - " + args[i+2]
+				m.cutsFlag = true
 				skip = 2
 				continue
 			}
 		}
+		if (arg == "-cut" || arg == "-cuts") && i+1 < len(args) {
+			if arg == "-cut" {
+				m.cutSpec = args[i+1]
+			} else if spec, err := readCutList(cleanPath(args[i+1])); err == nil {
+				m.cutSpec = spec
+			} else {
+				m.err = err
+			}
+			m.cutsFlag = true
+			skip = 1
+			continue
+		}
+		if arg == "-snap" {
+			m.snap = true
+			continue
+		}
+		if arg == "-deadair" && i+1 < len(args) {
+			if slices.Contains(deadAirModes, args[i+1]) {
+				m.deadAir = args[i+1]
+			} else {
+				m.err = fmt.Errorf("invalid -deadair %q: use ends or all", args[i+1])
+			}
+			skip = 1
+			continue
+		}
+		if arg == "-fade" && i+1 < len(args) {
+			if f, err := strconv.ParseFloat(args[i+1], 64); err == nil && f >= 0 {
+				m.fade = f
+			} else {
+				m.err = fmt.Errorf("invalid fade %q: use a length in seconds, e.g. 0.5", args[i+1])
+			}
+			skip = 1
+			continue
+		}
 
 		clean := cleanPath(arg)
-		if fi, err := os.Stat(clean); err == nil {
+		if _, err := os.Stat(clean); err == nil {
 			m.filePath = clean
-			m.originalSize = float64(fi.Size()) / 1024 / 1024
+			inputs = append(inputs, clean)
+		}
+	}
+	if m.split != nil && m.split.by != "" && (m.split.sizeMB > 0 || m.split.seconds > 0) {
+		m.err = fmt.Errorf("-chapters and -scenes can't be combined with -split-size or -split-time")
+	} else if m.split != nil && m.split.by == "" && m.split.sizeMB <= 0 && m.split.seconds <= 0 {
+		m.err = fmt.Errorf("-split-at needs -split-size or -split-time")
+	}
+	if m.stabilize != nil && m.stabilize.strength == "" && m.err == nil {
+		m.err = fmt.Errorf("-stab-zoom needs -stabilize")
+	}
+	if m.denoise.grain && m.denoise.preset == "" {
+		m.denoise.preset = "medium"
+	}
+	if _, ok := audioOutputs[m.audio.codec]; m.outputMode == modeAudio && m.audio.codec != "" && !ok {
+		m.err = fmt.Errorf("audio mode can't write %s: use -acodec opus, aac, mp3, vorbis or flac", m.audio.codec)
+	}
+	if m.deadAir != "" && m.cutsFlag {
+		m.err = fmt.Errorf("-deadair can't be combined with -trim, -cut or -cuts")
+	}
+	if m.join {
+		if len(inputs) < 2 {
+			m.err = fmt.Errorf("-join needs two or more input files")
+		} else if m.split != nil {
+			// a split plans its parts on the first input alone
+			m.err = fmt.Errorf("-join can't be combined with -split-size, -split-time, -chapters or -scenes")
+		} else if m.burn != nil && m.burn.file == "" {
+			// the joined file only carries video and audio
+			m.err = fmt.Errorf("-join can't burn in a subtitle stream: use -burn with a .srt, .ass or .vtt file")
+		} else {
+			m.joinFiles = inputs
+			m.filePath = inputs[0]
+		}
+	}
 
-			if m.outputMode == modeGIF || m.outputMode == modeAPNG {
-				m.state = stateInputRes
-				ti.Placeholder = "Enter=Original, 2=Half-size, or e.g. 1280x720"
+	m.textInput = ti
+	if m.filePath == "" {
+		m.textInput.Placeholder = "Drag & Drop or enter path..."
+	}
+	return m
+}
+
+// probedMsg carries the file step's probe of the input.
+type probedMsg struct {
+	file string
+	info *FFProbeOutput
+	err  error
+}
+
+// probeInput probes the wizard's input. A join is described as the joined
+// file, since that's what the wizard sets up.
+func probeInput(path string, joinFiles []string, reframe *reframeSettings) (*FFProbeOutput, error) {
+	if len(joinFiles) <= 1 {
+		return probeFile(path, "")
+	}
+	infos, err := probeJoin(joinFiles, "")
+	if err != nil {
+		return nil, err
+	}
+	return joinedInfo(infos, newJoinFormat(infos, reframe)), nil
+}
+
+// probeCmd probes the input in the background, so the wizard keeps
+// responding while ffprobe reads it.
+func (m model) probeCmd() tea.Cmd {
+	path, joinFiles, reframe := m.filePath, m.joinFiles, m.reframe
+	return func() tea.Msg {
+		info, err := probeInput(path, joinFiles, reframe)
+		return probedMsg{file: path, info: info, err: err}
+	}
+}
+
+// loadFile probes the input given on the command line before the program
+// starts and moves on to the step after the file step.
+func (m model) loadFile(path string) model {
+	m.filePath = path
+	info, err := probeInput(path, m.joinFiles, m.reframe)
+	return m.fileProbed(info, err)
+}
+
+// fileProbed takes in the probed input and moves on to the step after the
+// file step: stream selection if there is a choice to make, else the
+// settings.
+func (m model) fileProbed(info *FFProbeOutput, err error) model {
+	m.info = info
+	if len(m.joinFiles) > 1 {
+		if err != nil {
+			m.err = err
+		} else {
+			size, _ := strconv.ParseFloat(info.Format.Size, 64)
+			m.originalSize = size / 1024 / 1024
+		}
+	} else if fi, err := os.Stat(m.filePath); err == nil {
+		m.originalSize = float64(fi.Size()) / 1024 / 1024
+	} // startEncoding reports the probe errors of a single file
+	if m.info != nil {
+		m.streams = defaultStreams(m.info, m.streamIdx, m.audioLangs, m.subLangs)
+		if m.checksInterlace() {
+			m.interlacing = "checking..."
+		}
+		if m.cutSpec != "" {
+			if cuts, err := m.parseCuts(m.cutSpec); err != nil {
+				m.err = err
+				m.cutsFlag = false // let the trim step fix it
 			} else {
-				m.state = stateInputSize
-				ti.Placeholder = "e.g. 10 (for 10MB)"
+				m.cuts = cuts
 			}
 		}
 	}
 
-	if m.filePath == "" {
-		ti.Placeholder = "Drag & Drop or enter path..."
+	if m.showStreamStep() {
+		m.state = stateSelectStreams
+		m.streamCursor = 0
+		m.textInput.Blur()
+		return m
 	}
+	return m.afterStreams()
+}
 
-	m.textInput = ti
+// afterStreams moves to the cuts step, unless -trim, -cut or -cuts already
+// decided what to keep.
+func (m model) afterStreams() model {
+	if !m.showCutsStep() {
+		return m.afterCuts()
+	}
+	m.state = stateInputCuts
+	m.deadAirBusy = m.deadAir != "" && len(m.joinFiles) <= 1
+	m.deadAirNote = ""
+	m.textInput.Reset()
+	m.textInput.Focus()
+	m.textInput.Placeholder = "Enter=Whole file, or e.g. 0:10-0:20, 1:00-1:30"
+	m.textInput.SetValue(m.cutSpec)
 	return m
 }
 
+// parseCuts reads trim or cut ranges against the loaded file.
+func (m model) parseCuts(spec string) ([]keepRange, error) {
+	duration, _ := strconv.ParseFloat(m.info.Format.Duration, 64)
+	fps := 0.0
+	if v := m.info.stream(m.streams.video); v != nil {
+		fps = v.frameRate()
+	}
+	return parseCutList(spec, duration, fps)
+}
+
+// showCutsStep reports whether the wizard asks which parts to keep.
+func (m model) showCutsStep() bool {
+	return m.info != nil && !m.cutsFlag
+}
+
+// afterCuts moves to the crop step, or past it when there's nothing to
+// crop or -crop already decided.
+func (m model) afterCuts() model {
+	if !m.showCropStep() {
+		return m.firstSetting()
+	}
+	m.state = stateSelectCrop
+	m.cropDetecting = true
+	m.cropDetected = nil
+	m.cropNote = ""
+	m.textInput.Reset()
+	m.textInput.Focus()
+	m.textInput.Placeholder = "Enter=No crop, or W:H:X:Y, or e.g. 9:16 top"
+	return m
+}
+
+// showCropStep reports whether the wizard offers to crop the video.
+func (m model) showCropStep() bool {
+	return m.info != nil && m.outputMode != modeAudio && !m.cropFlag && len(m.joinFiles) == 0 && m.info.stream(m.streams.video) != nil
+}
+
+// firstSetting moves to the first settings step for the output mode.
+func (m model) firstSetting() model {
+	m.textInput.Reset()
+	m.textInput.Focus()
+	if m.outputMode == modeGIF || m.outputMode == modeAPNG {
+		m.state = stateInputRes
+		m.textInput.Placeholder = "Enter=Original, 2=Half-size, or e.g. 1280x720"
+	} else {
+		m.state = stateInputSize
+		m.textInput.Placeholder = "e.g. 10 (for 10MB)"
+	}
+	m.textInput.SetValue(m.presetValue())
+	return m
+}
+
+// showStreamStep reports whether the wizard asks which streams to keep.
+func (m model) showStreamStep() bool {
+	return m.info != nil && needsStreamChoice(m.info, m.outputMode == modeVideo) &&
+		len(m.streamIdx) == 0 && len(m.audioLangs) == 0 && len(m.subLangs) == 0
+}
+
+// streamList is what the stream step offers for the current output mode.
+func (m model) streamList() []probeStream {
+	return selectableStreams(m.info, m.outputMode == modeVideo)
+}
+
+// wizardSteps lists the steps the wizard walks through for the current mode
+// and answers so far, in order.
+func (m model) wizardSteps() []state {
+	steps := []state{stateInputFile}
+	if m.showStreamStep() {
+		steps = append(steps, stateSelectStreams)
+	}
+	if m.showCutsStep() {
+		steps = append(steps, stateInputCuts)
+	}
+	if m.showCropStep() {
+		steps = append(steps, stateSelectCrop)
+	}
+	switch m.outputMode {
+	case modeGIF, modeAPNG:
+		return append(steps, stateInputRes, stateFPS)
+	case modeAudio:
+		steps = append(steps, stateInputSize)
+		if m.targetSizeMB <= 0 {
+			steps = append(steps, stateSelectCRF)
+		}
+		return steps
+	}
+	steps = append(steps, stateInputSize, stateInputRes, stateFPS, stateSelectHW, stateSelectCodec)
+	if m.targetSizeMB <= 0 {
+		steps = append(steps, stateSelectCRF)
+	}
+	return append(steps, stateSelectQuality)
+}
+
+// stepTitle renders a step heading numbered by its place in wizardSteps.
+func (m model) stepTitle(st state, title string) string {
+	return stepStyle.Render(fmt.Sprintf("%d. %s", slices.Index(m.wizardSteps(), st)+1, title))
+}
+
+// presetValue returns the value given on the command line for the current
+// text step, so e.g. -size only needs Enter to confirm.
+func (m model) presetValue() string {
+	switch m.state {
+	case stateInputSize:
+		if m.targetSizeMB > 0 {
+			return strconv.FormatFloat(m.targetSizeMB, 'f', -1, 64)
+		}
+	case stateInputRes:
+		return m.targetRes
+	case stateFPS:
+		return m.targetFPS
+	}
+	return ""
+}
+
 func (m model) Init() tea.Cmd {
-	return textinput.Blink
+	cmds := []tea.Cmd{textinput.Blink}
+	if m.info != nil {
+		cmds = append(cmds, m.detectInterlaceCmd())
+	}
+	switch m.state {
+	case stateInputCuts:
+		cmds = append(cmds, m.detectDeadAirCmd())
+	case stateSelectCrop:
+		cmds = append(cmds, m.detectCropCmd())
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
+	var cmds []tea.Cmd
+	prevState := m.state
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -266,43 +979,123 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			if msg.Type == tea.KeyEnter {
 				path := cleanPath(m.textInput.Value())
-				if fi, err := os.Stat(path); err != nil {
+				if _, err := os.Stat(path); err != nil {
 					m.err = fmt.Errorf("file not found: %s", path)
 				} else {
 					m.filePath = path
-					m.originalSize = float64(fi.Size()) / 1024 / 1024
+					m.err = nil
+					m.state = stateProbing
+					m.textInput.Blur()
+					return m, m.probeCmd()
+				}
+			}
 
-					if m.outputMode == modeGIF || m.outputMode == modeAPNG {
-						m.state = stateInputRes
-						m.textInput.Reset()
-						m.textInput.Placeholder = "Enter=Original, 2=Half-size, or e.g. 1280x720"
-					} else {
-						m.state = stateInputSize
-						m.textInput.Reset()
-						m.textInput.Placeholder = "e.g. 10 (for 10MB)"
+		case stateSelectStreams:
+			list := m.streamList()
+			switch msg.String() {
+			case "up", "k", "w":
+				if m.streamCursor > 0 {
+					m.streamCursor--
+				}
+			case "down", "j", "s":
+				if m.streamCursor < len(list)-1 {
+					m.streamCursor++
+				}
+			case " ", "x":
+				video := m.streams.video
+				m.streams = m.streams.toggle(list[m.streamCursor], m.outputMode == modeAudio)
+				if m.streams.video != video && m.interlacing != "" {
+					// the check was of the other stream; the job checks this one
+					m.deinterlace.mode, m.deinterlace.parity = deinterlaceAuto, ""
+					m.interlacing = ""
+				}
+			case "b":
+				st := list[m.streamCursor]
+				if st.CodecType != "subtitle" {
+					break
+				}
+				if m.burn != nil && m.burn.file == "" && m.burn.stream == st.Index {
+					m.burn = nil
+				} else if !slices.Contains(textSubtitles, st.CodecName) {
+					m.err = fmt.Errorf("%s subtitles are images and can't be burned in", st.CodecName)
+				} else {
+					m.burn = &burnIn{stream: st.Index}
+					m.err = nil
+				}
+			case "enter":
+				if m.outputMode == modeAudio && len(m.streams.audio) == 0 {
+					m.err = fmt.Errorf("select an audio stream")
+				} else if m.outputMode != modeAudio && m.streams.video < 0 {
+					m.err = fmt.Errorf("select a video stream")
+				} else {
+					m.err = nil
+					m = m.afterStreams()
+				}
+			}
+
+		case stateInputCuts:
+			if msg.Type == tea.KeyTab && m.outputMode != modeAudio {
+				m.snap = !m.snap
+				return m, nil
+			}
+			if msg.Type == tea.KeyEnter {
+				if cuts, err := m.parseCuts(m.textInput.Value()); err != nil {
+					m.err = err
+				} else {
+					m.cuts = cuts
+					m.err = nil
+					if len(m.joinFiles) <= 1 {
+						m.deadAir = "" // proposed here, so what was entered stands
+					}
+					m = m.afterCuts()
+				}
+			}
+
+		case stateSelectCrop:
+			if msg.Type == tea.KeyEnter {
+				v := m.info.stream(m.streams.video)
+				if rect, err := parseCrop(m.textInput.Value(), v.Width, v.Height); err != nil {
+					m.err = err
+				} else {
+					m.crop = ""
+					if rect != nil {
+						m.crop = strings.TrimSpace(m.textInput.Value())
 					}
 					m.err = nil
+					m = m.firstSetting()
 				}
 			}
 
 		case stateInputSize:
 			if msg.Type == tea.KeyEnter {
 				val := m.textInput.Value()
-				if val == "" {
+				if val == "" && m.outputMode == modeAudio {
+					m.targetSizeMB = 0 // quality mode
+					m.state = stateSelectCRF
+					m.textInput.Blur()
+					m.err = nil
+				} else if val == "" {
 					m.targetSizeMB = 0 // will use CRF mode
 					m.state = stateInputRes
 					m.textInput.Reset()
 					m.textInput.Placeholder = "Enter=Original, 2=Half-size, or e.g. 1280x720"
+					m.textInput.SetValue(m.presetValue())
 					m.err = nil
 				} else {
 					size, err := strconv.ParseFloat(val, 64)
 					if err != nil || size <= 0 {
 						m.err = fmt.Errorf("invalid size")
+					} else if m.outputMode == modeAudio {
+						m.targetSizeMB = size
+						m.err = nil
+						m.textInput.Blur()
+						return m.startJob()
 					} else {
 						m.targetSizeMB = size
 						m.state = stateInputRes
 						m.textInput.Reset()
 						m.textInput.Placeholder = "Enter=Original, 2=Half-size, or e.g. 1280x720"
+						m.textInput.SetValue(m.presetValue())
 						m.err = nil
 					}
 				}
@@ -311,9 +1104,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case stateInputRes:
 			if msg.Type == tea.KeyEnter {
 				m.targetRes = m.textInput.Value()
+				if isReframe(m.targetRes) {
+					r, err := parseReframe(m.targetRes)
+					if err != nil {
+						m.err = err
+						break
+					}
+					m.reframe, m.targetRes = r, ""
+				}
 				m.textInput.Reset()
 				m.state = stateFPS
 				m.textInput.Placeholder = "Enter=Original, or e.g. 30, 60"
+				m.textInput.SetValue(m.presetValue())
 				m.err = nil
 			}
 
@@ -323,21 +1125,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.textInput.Blur()
 
 				if m.outputMode == modeGIF || m.outputMode == modeAPNG {
-					m.state = stateProcessing
-					m.progressChan = make(chan progressMsg)
-					var codecCfg codecInfo
-					switch m.outputMode {
-					case modeGIF:
-						codecCfg = codecInfo{Name: "GIF", Ext: ".gif"}
-					case modeAPNG:
-						codecCfg = codecInfo{Name: "APNG", Ext: ".png"}
-					}
-
-					return m, tea.Batch(
-						m.spinner.Tick,
-						startEncoding(m.filePath, m.targetSizeMB, m.targetRes, m.targetFPS, m.trimStart, m.trimEnd, m.customOut, hwCPU, codecCfg, m.progressChan, m.outputMode, m.qualityLevel, m.crfLevel),
-						waitForProgress(m.progressChan),
-					)
+					return m.startJob()
 				} else {
 					m.state = stateSelectHW
 					m.textInput.Blur()
@@ -350,28 +1138,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "up", "k", "w":
 				if m.selectedHW > 0 {
 					m.selectedHW--
+					m.selectedCodec = 0
 				}
 			case "down", "j", "s":
 				if m.selectedHW < len(hardwareOptions)-1 {
 					m.selectedHW++
+					m.selectedCodec = 0
 				}
 			case "enter":
 				m.state = stateSelectCodec
-				m.selectedCodec = 0
 			}
 
 		case stateSelectCodec:
-			hw := hardwareOptions[m.selectedHW]
-			options := encoderMap[hw]
-			if m.outputMode == modeAVIF {
-				var av1Options []codecInfo
-				for _, c := range options {
-					if strings.Contains(c.FFmpegLib, "av1") {
-						av1Options = append(av1Options, c)
-					}
-				}
-				options = av1Options
-			}
+			options := codecOptions(hardwareOptions[m.selectedHW], m.outputMode)
 
 			switch msg.String() {
 			case "up", "k", "w":
@@ -386,6 +1165,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if len(options) == 0 {
 					return m, nil
 				}
+				if m.outputMode == modeVideo {
+					opts := m.encodeOptions()
+					if _, _, err := checkContainer(containers[opts.container], opts.codecCfg.FFmpegLib, ""); err != nil {
+						m.err = err
+						return m, nil
+					}
+				}
+				m.err = nil
 				if m.targetSizeMB <= 0 {
 					m.state = stateSelectCRF
 				} else {
@@ -404,6 +1191,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.crfLevel++
 				}
 			case "enter":
+				if m.outputMode == modeAudio {
+					return m.startJob()
+				}
 				m.state = stateSelectQuality
 			}
 
@@ -418,38 +1208,113 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.qualityLevel++
 				}
 			case "enter":
-				hw := hardwareOptions[m.selectedHW]
-				options := encoderMap[hw]
-				if m.outputMode == modeAVIF {
-					var av1Options []codecInfo
-					for _, c := range options {
-						if strings.Contains(c.FFmpegLib, "av1") {
-							av1Options = append(av1Options, c)
-						}
-					}
-					options = av1Options
-				}
-				codecCfg := options[m.selectedCodec]
+				return m.startJob()
+			}
 
-				m.state = stateProcessing
-				m.progressChan = make(chan progressMsg)
+		case stateConfirmPassthrough:
+			switch msg.String() {
+			case "c":
+				m.passthrough = passCopy
+				return m.prepareOutput()
+			case "e":
+				m.passthrough = passEncode
+				return m.prepareOutput()
+			case "s", "n":
+				return m, skipJob("", m.passReason)
+			}
 
-				return m, tea.Batch(
-					m.spinner.Tick,
-					startEncoding(m.filePath, m.targetSizeMB, m.targetRes, m.targetFPS, m.trimStart, m.trimEnd, m.customOut, hw, codecCfg, m.progressChan, m.outputMode, m.qualityLevel, m.crfLevel),
-					waitForProgress(m.progressChan),
-				)
+		case stateConfirmOverwrite:
+			switch msg.String() {
+			case "o", "y":
+				m.overwrite = true
+				return m.runJob()
+			case "r":
+				m.outputFile = m.naming.next(m.encodeOptions())
+				return m.runJob()
+			case "s", "n", "enter":
+				return m, skipJob(m.outputFile, "output already exists")
 			}
 		}
 
+	case cropDetectedMsg:
+		if msg.file != m.filePath || m.state != stateSelectCrop {
+			return m, nil
+		}
+		m.cropDetecting = false
+		switch {
+		case msg.err != nil:
+			m.cropNote = "Crop detection failed: " + msg.err.Error()
+		case msg.rect == nil:
+			m.cropNote = "No black bars found."
+		default:
+			m.cropDetected = msg.rect
+			if m.textInput.Value() == "" {
+				m.textInput.SetValue(msg.rect.String())
+				m.textInput.CursorEnd()
+			}
+		}
+		return m, nil
+
+	case probedMsg:
+		if msg.file != m.filePath || m.state != stateProbing {
+			return m, nil
+		}
+		// the next step's own commands start below
+		m = m.fileProbed(msg.info, msg.err)
+		cmds = append(cmds, m.detectInterlaceCmd())
+
+	case interlaceDetectedMsg:
+		if msg.file != m.filePath || m.deinterlace.mode != deinterlaceAuto {
+			return m, nil
+		}
+		switch {
+		case msg.video != m.streams.video:
+			m.interlacing = "" // another stream was picked; the job checks that one
+		case msg.err != nil:
+			// the job checks again, and falls back to the stream's flags
+			m.interlacing = "check failed"
+		case msg.interlaced:
+			m.deinterlace.mode, m.deinterlace.parity = deinterlaceOn, msg.parity
+			m.interlacing = strings.ToUpper(msg.parity) + ", deinterlacing"
+		default:
+			m.deinterlace.mode = deinterlaceOff
+			m.interlacing = "progressive"
+		}
+		return m, nil
+
+	case deadAirDetectedMsg:
+		if msg.file != m.filePath || m.state != stateInputCuts {
+			return m, nil
+		}
+		m.deadAirBusy = false
+		total, _ := strconv.ParseFloat(m.info.Format.Duration, 64)
+		switch {
+		case msg.err != nil:
+			m.deadAirNote = "Dead air detection failed: " + msg.err.Error()
+		case msg.report.keep == nil:
+			m.deadAirNote = "No dead air found."
+		default:
+			m.deadAirNote = fmt.Sprintf("Dead air found: %s. Proposed trim below.", msg.report.summary(total))
+			if m.textInput.Value() == "" {
+				m.textInput.SetValue(formatRanges(msg.report.keep))
+				m.textInput.CursorEnd()
+			}
+		}
+		return m, nil
+
 	case progressMsg:
-		m.currentLog = msg.line
+		if msg.line != "" {
+			m.currentLog = msg.line
+		}
 		if msg.progress > 0 {
 			m.percent = msg.progress
 		}
 		if msg.debugCmd != "" {
 			m.currentCmd = msg.debugCmd
 		}
+		if msg.kind == evWarning {
+			m.warnings = append(m.warnings, msg.line)
+		}
 		return m, waitForProgress(m.progressChan)
 
 	case workDoneMsg:
@@ -460,6 +1325,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.state = stateDone
 			m.outputFile = msg.outputFile
 			m.finalSize = msg.finalSize
+			m.logPath = msg.logPath
+			m.skipReason = msg.skipReason
+			m.loudness = msg.loudness
+			m.parts = msg.parts
 		}
 		return m, tea.Quit
 
@@ -470,11 +1339,69 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	if m.state == stateInputFile || m.state == stateInputSize || m.state == stateInputRes || m.state == stateFPS {
+	if m.state == stateInputFile || m.state == stateInputCuts || m.state == stateSelectCrop || m.state == stateInputSize || m.state == stateInputRes || m.state == stateFPS {
 		m.textInput, cmd = m.textInput.Update(msg)
 	}
+	cmds = append(cmds, cmd)
+	if m.state == stateInputCuts && prevState != stateInputCuts {
+		cmds = append(cmds, m.detectDeadAirCmd())
+	}
+	if m.state == stateSelectCrop && prevState != stateSelectCrop {
+		cmds = append(cmds, m.detectCropCmd())
+	}
 
-	return m, cmd
+	return m, tea.Batch(cmds...)
+}
+
+// startJob checks whether the input needs encoding at all, asking the user
+// if it already meets the target and the passthrough policy is "ask".
+func (m model) startJob() (tea.Model, tea.Cmd) {
+	// without info the file step's probe failed, which startEncoding reports
+	if m.outputMode == modeVideo && m.passthrough == passAsk && m.info != nil {
+		opts := m.encodeOptions()
+		if reason := passthroughReason(opts, m.info, jobDuration(opts, m.info)); reason != "" {
+			m.passReason = reason
+			m.state = stateConfirmPassthrough
+			return m, nil
+		}
+	}
+	return m.prepareOutput()
+}
+
+// prepareOutput resolves the output path and starts encoding, asking first if
+// that would replace an existing file and the policy doesn't say what to do.
+func (m model) prepareOutput() (tea.Model, tea.Cmd) {
+	path, exists := m.naming.resolve(m.encodeOptions())
+	m.outputFile = path
+	if exists {
+		switch m.naming.policy {
+		case collisionSkip:
+			return m, skipJob(path, "output already exists")
+		case collisionOverwrite:
+			m.overwrite = true
+		default:
+			m.state = stateConfirmOverwrite
+			return m, nil
+		}
+	}
+	return m.runJob()
+}
+
+func (m model) runJob() (tea.Model, tea.Cmd) {
+	m.state = stateProcessing
+	m.progressChan = make(chan progressMsg)
+
+	return m, tea.Batch(
+		m.spinner.Tick,
+		startEncoding(m.encodeOptions(), m.progressChan),
+		waitForProgress(m.progressChan),
+	)
+}
+
+func skipJob(path, reason string) tea.Cmd {
+	return func() tea.Msg {
+		return workDoneMsg{outputFile: path, skipReason: reason}
+	}
 }
 
 func (m model) View() string {
@@ -488,26 +1415,151 @@ func (m model) View() string {
 		title += "(APNG Mode)"
 	case modeAVIF:
 		title += "(AVIF Mode)"
+	case modeAudio:
+		title += "(Audio Mode)"
 	}
 	s.WriteString(titleStyle.Render(title))
-	if m.trimStart != "" {
-		s.WriteString(fmt.Sprintf(" [Trim: %s-%s]", m.trimStart, m.trimEnd))
+	if len(m.joinFiles) > 1 {
+		s.WriteString(fmt.Sprintf(" [Join: %d files]", len(m.joinFiles)))
+	}
+	if m.split != nil {
+		s.WriteString(fmt.Sprintf(" [Split: %s]", m.split.label()))
+	}
+	switch {
+	case len(m.cuts) == 1:
+		s.WriteString(fmt.Sprintf(" [Trim: %s]", m.cuts[0]))
+	case len(m.cuts) > 1:
+		s.WriteString(fmt.Sprintf(" [Cuts: %d ranges, %s]", len(m.cuts), formatDuration(keptDuration(m.cuts))))
+	}
+	if m.fade > 0 {
+		s.WriteString(fmt.Sprintf(" [Fade: %gs]", m.fade))
+	}
+	switch {
+	case m.interlacing != "" && m.outputMode != modeAudio:
+		s.WriteString(fmt.Sprintf(" [Interlace: %s]", m.interlacing))
+	case m.deinterlace.mode == deinterlaceOn || m.deinterlace.mode == deinterlaceDouble:
+		s.WriteString(fmt.Sprintf(" [Deinterlace: %s]", m.deinterlace.mode))
+	case m.deinterlace.mode == deinterlaceAuto && m.info != nil && m.outputMode != modeAudio:
+		if v := m.info.stream(m.streams.video); v != nil && interlacedOrder(v.FieldOrder) {
+			s.WriteString(" [Interlaced: auto deinterlace]")
+		}
+	}
+	if m.denoise.preset != "" && m.outputMode != modeAudio {
+		s.WriteString(fmt.Sprintf(" [Denoise: %s]", m.denoise.preset))
+	}
+	if m.stabilize != nil && m.outputMode != modeAudio {
+		s.WriteString(fmt.Sprintf(" [Stabilize: %s]", m.stabilize.label()))
+	}
+	if m.burn != nil {
+		s.WriteString(fmt.Sprintf(" [Burn: %s]", m.burn.label()))
+	}
+	if m.crop != "" && m.state != stateSelectCrop {
+		s.WriteString(fmt.Sprintf(" [Crop: %s]", m.crop))
+	}
+	if m.reframe != nil {
+		s.WriteString(fmt.Sprintf(" [Reframe: %s]", m.reframe.label()))
+	}
+	if m.speed != 1 {
+		s.WriteString(fmt.Sprintf(" [Speed: %gx]", m.speed))
 	}
 	s.WriteString("\n\n")
 
-	if m.err != nil {
+	if m.err != nil && m.state != stateError {
 		s.WriteString(errStyle.Render(fmt.Sprintf("ERROR: %v", m.err)))
 		s.WriteString("\n\n")
 	}
 
 	switch m.state {
 	case stateInputFile:
-		s.WriteString(stepStyle.Render("1. Select Video File"))
+		s.WriteString(m.stepTitle(stateInputFile, "Select Video File"))
 		s.WriteString("\nDrag & Drop file:\n\n")
 		s.WriteString(m.textInput.View())
 
+	case stateProbing:
+		s.WriteString(m.stepTitle(stateInputFile, "Select Video File"))
+		s.WriteString(fmt.Sprintf("\nReading %s...", filepath.Base(m.filePath)))
+
+	case stateSelectStreams:
+		s.WriteString(m.stepTitle(stateSelectStreams, "Select Streams"))
+		s.WriteString(fmt.Sprintf("\nFile: %s", filepath.Base(m.filePath)))
+		if m.outputMode == modeAudio {
+			s.WriteString("\nSpace to toggle, Enter to continue.\n\n")
+		} else {
+			s.WriteString("\nSpace to toggle, B to burn in a subtitle, Enter to continue.\n\n")
+		}
+		for i, st := range m.streamList() {
+			cursor := "  "
+			style := itemStyle
+			if m.streamCursor == i {
+				cursor = "> "
+				style = selectedItemStyle
+			}
+			check := "[ ] "
+			if m.streams.has(st.Index) {
+				check = "[x] "
+			}
+			burn := ""
+			if m.burn != nil && m.burn.file == "" && m.burn.stream == st.Index {
+				burn = " [burn]"
+			}
+			s.WriteString(style.Render(cursor+check+st.label()+burn) + "\n")
+		}
+
+	case stateInputCuts:
+		s.WriteString(m.stepTitle(stateInputCuts, "Trim"))
+		s.WriteString(fmt.Sprintf("\nFile: %s", filepath.Base(m.filePath)))
+		total, _ := strconv.ParseFloat(m.info.Format.Duration, 64)
+		if total > 0 {
+			s.WriteString(fmt.Sprintf(" (%s)", formatDuration(total)))
+		}
+		s.WriteString("\nEnter to keep the whole file, or in-out points like 1:00-1:30, 90-1m45s, 1200f-2400f")
+		s.WriteString("\nor 10 -5 (5s before the end). Several ranges, comma-separated, are joined in order.")
+		if m.outputMode != modeAudio {
+			cutMode := "frame-accurate"
+			if m.snap {
+				cutMode = "snap to the nearest keyframes (stream copy when nothing else needs an encode)"
+			}
+			s.WriteString("\nTab to switch cut points: " + cutMode)
+		}
+		switch {
+		case m.deadAirBusy:
+			s.WriteString("\nDetecting dead air...")
+		case m.deadAirNote != "":
+			s.WriteString("\n" + m.deadAirNote)
+		case m.deadAir != "":
+			s.WriteString("\nDead air is trimmed from the joined file when encoding.")
+		}
+		s.WriteString("\n\n" + m.textInput.View() + "\n")
+		if input := strings.TrimSpace(m.textInput.Value()); input != "" {
+			if cuts, err := m.parseCuts(input); err != nil {
+				s.WriteString(lipgloss.NewStyle().Faint(true).Render(err.Error()))
+			} else if kept := keptDuration(cuts); len(cuts) == 1 {
+				s.WriteString(fmt.Sprintf("Clip: %s of %s", formatDuration(kept), formatDuration(total)))
+			} else {
+				s.WriteString(fmt.Sprintf("Clip: %s of %s in %d ranges", formatDuration(kept), formatDuration(total), len(cuts)))
+			}
+		}
+
+	case stateSelectCrop:
+		s.WriteString(m.stepTitle(stateSelectCrop, "Crop"))
+		s.WriteString(fmt.Sprintf("\nFile: %s", filepath.Base(m.filePath)))
+		if v := m.info.stream(m.streams.video); v != nil {
+			s.WriteString(fmt.Sprintf(" (%dx%d)", v.Width, v.Height))
+		}
+		switch {
+		case m.cropDetecting:
+			s.WriteString("\nDetecting black bars...")
+		case m.cropDetected != nil:
+			r := m.cropDetected
+			s.WriteString(fmt.Sprintf("\nBlack bars found: crop to %dx%d at %d,%d.", r.W, r.H, r.X, r.Y))
+		default:
+			s.WriteString("\n" + m.cropNote)
+		}
+		s.WriteString("\nEnter to accept, edit W:H:X:Y, or type an aspect ratio like 9:16 [top|bottom|left|right]:\n\n")
+		s.WriteString(m.textInput.View())
+
 	case stateInputSize:
-		s.WriteString(stepStyle.Render("2. Target Size"))
+		s.WriteString(m.stepTitle(stateInputSize, "Target Size"))
 		s.WriteString(fmt.Sprintf("\nFile: %s", filepath.Base(m.filePath)))
 		switch m.outputMode {
 		case modeGIF:
@@ -516,27 +1568,29 @@ func (m model) View() string {
 			s.WriteString("\nMax MB (APNG), Empty=CRF:\n\n")
 		case modeAVIF:
 			s.WriteString("\nMax MB (AVIF), Empty=CRF:\n\n")
+		case modeAudio:
+			s.WriteString("\nMax MB (Audio), Empty=Quality:\n\n")
 		default:
 			s.WriteString("\nMax MB (Audio+Video), Empty=CRF:\n\n")
 		}
 		s.WriteString(m.textInput.View())
 
 	case stateInputRes:
-		s.WriteString(stepStyle.Render("3. Target Resolution"))
+		s.WriteString(m.stepTitle(stateInputRes, "Target Resolution"))
 		s.WriteString("\nLeave empty for original.")
 		s.WriteString("\nType '2' for half size (1/2).")
-		s.WriteString("\nType '1280x720' for fixed size.\n\n")
+		s.WriteString("\nType '1280x720' for fixed size.")
+		s.WriteString("\nType '9:16', '1:1 pad' or '4:5 blur' to reframe.\n\n")
 		s.WriteString(m.textInput.View())
 
 	case stateFPS:
-		stepTitle := "4. Target Framerate (FPS)"
-		s.WriteString(stepStyle.Render(stepTitle))
+		s.WriteString(m.stepTitle(stateFPS, "Target Framerate (FPS)"))
 		s.WriteString("\nLeave empty for original FPS.")
 		s.WriteString("\nEnter a number (e.g. 30, 60) to set FPS.\n\n")
 		s.WriteString(m.textInput.View())
 
 	case stateSelectHW:
-		s.WriteString(stepStyle.Render("5. Select Hardware"))
+		s.WriteString(m.stepTitle(stateSelectHW, "Select Hardware"))
 		if m.targetSizeMB > 0 {
 			s.WriteString(fmt.Sprintf("\nTarget: %.2f MB\n\n", m.targetSizeMB))
 		} else {
@@ -553,23 +1607,14 @@ func (m model) View() string {
 		}
 
 	case stateSelectCodec:
-		s.WriteString(stepStyle.Render("6. Select Codec"))
+		s.WriteString(m.stepTitle(stateSelectCodec, "Select Codec"))
 		if m.outputMode == modeAVIF {
 			s.WriteString(" (AV1 only)")
 		}
 		hw := hardwareOptions[m.selectedHW]
 		s.WriteString(fmt.Sprintf("\nHardware: %s\n\n", hw))
 
-		options := encoderMap[hw]
-		if m.outputMode == modeAVIF {
-			var av1Options []codecInfo
-			for _, c := range options {
-				if strings.Contains(c.FFmpegLib, "av1") {
-					av1Options = append(av1Options, c)
-				}
-			}
-			options = av1Options
-		}
+		options := codecOptions(hw, m.outputMode)
 
 		for i, c := range options {
 			cursor := "  "
@@ -582,7 +1627,24 @@ func (m model) View() string {
 		}
 
 	case stateSelectCRF:
-		s.WriteString(stepStyle.Render("7. Quality (CRF)"))
+		if m.outputMode == modeAudio {
+			s.WriteString(m.stepTitle(stateSelectCRF, "Quality"))
+			s.WriteString("\nAdjust the audio bitrate.\n\n")
+
+			line := ""
+			for i := 0; i <= 20; i++ {
+				if i == m.crfLevel*2 {
+					line += "○"
+				} else {
+					line += "━"
+				}
+			}
+			s.WriteString(fmt.Sprintf("  High Quality  [ %s ]  Smaller File\n", line))
+			s.WriteString(fmt.Sprintf("  Bitrate: %s\n", selectedItemStyle.Render(fmt.Sprintf("%d kbit/s", audioQualityKBit(m.crfLevel)))))
+			s.WriteString("\nPress Enter to start.")
+			break
+		}
+		s.WriteString(m.stepTitle(stateSelectCRF, "Quality (CRF)"))
 		s.WriteString("\nAdjust the Constant Rate Factor (CRF).")
 		s.WriteString("\n\n")
 
@@ -603,11 +1665,7 @@ func (m model) View() string {
 		s.WriteString("\nPress Enter to continue.")
 
 	case stateSelectQuality:
-		stepNum := "7"
-		if m.targetSizeMB <= 0 {
-			stepNum = "8"
-		}
-		s.WriteString(stepStyle.Render(stepNum + ". Select Encoding Speed"))
+		s.WriteString(m.stepTitle(stateSelectQuality, "Select Encoding Speed"))
 		s.WriteString("\nUse Left/Right to adjust.")
 		s.WriteString("\n\n")
 
@@ -627,8 +1685,25 @@ func (m model) View() string {
 
 		s.WriteString(fmt.Sprintf("  Fast  [ %s ]  Slow\n", line))
 		s.WriteString("  Mode: " + selectedItemStyle.Render(currentLabel))
+		if m.denoise.preset != "" {
+			s.WriteString("\n  Denoise: " + selectedItemStyle.Render(m.denoise.label(m.encodeOptions().codecCfg.FFmpegLib)))
+		}
 		s.WriteString("\n\nPress Enter to start.")
 
+	case stateConfirmPassthrough:
+		s.WriteString(stepStyle.Render("Already Compressed"))
+		s.WriteString(fmt.Sprintf("\n%s: %s.\n\n", filepath.Base(m.filePath), m.passReason))
+		s.WriteString("  c = copy streams without re-encoding\n")
+		s.WriteString("  e = encode anyway\n")
+		s.WriteString("  s = skip")
+
+	case stateConfirmOverwrite:
+		s.WriteString(stepStyle.Render("File Exists"))
+		s.WriteString(fmt.Sprintf("\n%s already exists.\n\n", m.outputFile))
+		s.WriteString("  o = overwrite\n")
+		s.WriteString("  r = save as " + filepath.Base(m.naming.next(m.encodeOptions())) + "\n")
+		s.WriteString("  s = skip")
+
 	case stateProcessing:
 		mode := "Compressing"
 		switch m.outputMode {
@@ -638,6 +1713,8 @@ func (m model) View() string {
 			mode = "Creating APNG"
 		case modeAVIF:
 			mode = "Creating AVIF"
+		case modeAudio:
+			mode = "Compressing Audio"
 		}
 		s.WriteString(stepStyle.Render(mode + "..."))
 		s.WriteString("\n\n")
@@ -649,6 +1726,9 @@ func (m model) View() string {
 
 		s.WriteString(fmt.Sprintf("%s %s  %.0f%%\n\n", m.spinner.View(), bar, m.percent*100))
 		s.WriteString(lipgloss.NewStyle().Faint(true).Render("Status: " + m.currentLog))
+		for _, w := range m.warnings {
+			s.WriteString("\n" + selectedItemStyle.Render("Warning: ") + w)
+		}
 
 		if m.verbose && m.currentCmd != "" {
 			s.WriteString("\n\n")
@@ -656,12 +1736,36 @@ func (m model) View() string {
 		}
 
 	case stateDone:
+		if m.skipReason != "" {
+			s.WriteString(doneStyle.Render("Skipped."))
+			s.WriteString(fmt.Sprintf("\n\n%s: %s.", filepath.Base(m.filePath), m.skipReason))
+			break
+		}
 		s.WriteString(doneStyle.Render("Success!"))
-		s.WriteString(fmt.Sprintf("\n\nSaved to:\n%s", m.outputFile))
+		if len(m.parts) > 0 {
+			s.WriteString("\n\nSaved to:")
+			for _, p := range m.parts {
+				s.WriteString(fmt.Sprintf("\n%s (%s)", p.outputFile, p.finalSize))
+			}
+		} else {
+			s.WriteString(fmt.Sprintf("\n\nSaved to:\n%s", m.outputFile))
+		}
 		s.WriteString(fmt.Sprintf("\n%s", m.finalSize))
+		if m.loudness != nil {
+			s.WriteString(fmt.Sprintf("\nLoudness: %s → %s (target %.0f LUFS)", formatLUFS(m.loudness.Before), formatLUFS(m.loudness.After), m.loudness.Target))
+		}
+		for _, w := range m.warnings {
+			s.WriteString("\n" + selectedItemStyle.Render("Warning: ") + w)
+		}
+		if m.verbose && m.logPath != "" {
+			s.WriteString(lipgloss.NewStyle().Faint(true).Render("\nFFmpeg log: " + m.logPath))
+		}
 
 	case stateError:
 		s.WriteString(errStyle.Render("Failed."))
+		if m.err != nil {
+			s.WriteString("\n\n" + m.err.Error())
+		}
 	}
 
 	return appStyle.Render(s.String())
@@ -676,472 +1780,888 @@ func waitForProgress(sub <-chan progressMsg) tea.Cmd {
 	}
 }
 
-func buildScaleFilter(input string) string {
+// buildScaleFilter returns the scale filter for the resolution step, or nil
+// to keep the input size.
+func buildScaleFilter(input string) *videoFilter {
 	input = strings.TrimSpace(input)
 	if input == "" || input == "1" {
-		return ""
+		return nil
 	}
 	if div, err := strconv.ParseFloat(input, 64); err == nil && div > 0 {
-		return fmt.Sprintf("scale=trunc((iw/%g)/2)*2:trunc((ih/%g)/2)*2", div, div)
+		f := newFilter(stageScale, "scale",
+			pos(fmt.Sprintf("trunc((iw/%g)/2)*2", div)),
+			pos(fmt.Sprintf("trunc((ih/%g)/2)*2", div)))
+		return &f
 	}
-	if strings.Contains(input, "x") || strings.Contains(input, ":") {
-		formatted := strings.ReplaceAll(input, "x", ":")
-		return fmt.Sprintf("scale=%s", formatted)
+	if w, h, ok := strings.Cut(strings.ReplaceAll(input, "x", ":"), ":"); ok {
+		f := newFilter(stageScale, "scale", pos(w), pos(h))
+		return &f
 	}
-	return ""
+	return nil
 }
 
-func parseDuration(s string) float64 {
-	s = strings.TrimSuffix(s, "s")
-	parts := strings.Split(s, ":")
-	sec := 0.0
-	mul := 1.0
-	for i := len(parts) - 1; i >= 0; i-- {
-		v, _ := strconv.ParseFloat(parts[i], 64)
-		sec += v * mul
-		mul *= 60
-	}
-	return sec
+// validResolution reports whether input is something buildScaleFilter
+// understands: a divisor such as 2, or a size such as 1280x720 or 1280:-2.
+func validResolution(input string) bool {
+	input = strings.TrimSpace(input)
+	if div, err := strconv.ParseFloat(input, 64); err == nil {
+		return div > 0
+	}
+	w, h, ok := strings.Cut(strings.ReplaceAll(input, "x", ":"), ":")
+	_, errW := strconv.Atoi(w)
+	_, errH := strconv.Atoi(h)
+	return input == "" || (ok && errW == nil && errH == nil)
 }
 
-func startEncoding(inputFile string, targetMB float64, resInput string, fpsInput string, trimStart, trimEnd, customOut string, hw hwType, codecCfg codecInfo, progressChan chan progressMsg, mode outputMode, quality int, crfSlider int) tea.Cmd {
-	return func() tea.Msg {
-		defer close(progressChan)
+// validFrameRate reports whether input is a positive frame rate, either a
+// number such as 29.97 or a ratio such as 30000/1001.
+func validFrameRate(input string) bool {
+	num, den, ratio := strings.Cut(strings.TrimSpace(input), "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return false
+	}
+	if !ratio {
+		return true
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	return err == nil && d > 0
+}
 
-		progressChan <- progressMsg{line: "Analyzing file...", progress: 0}
-		info, err := probeFile(inputFile)
-		if err != nil {
-			return workDoneMsg{err: err}
-		}
+// encodeOptions holds everything a single job needs. The wizard and -json
+// mode both build it from the model, so they always run the same job.
+type encodeOptions struct {
+	inputFile    string
+	joinFiles    []string // several inputs to concatenate; inputFile is the first
+	split        *splitSettings
+	part         string       // {part} of the output name
+	naming       outputNaming // names the parts of a split job
+	targetMB     float64
+	resInput     string
+	fpsInput     string
+	cuts         []keepRange // from -trim, -cut or the wizard; nil = the whole file
+	snap         bool
+	fade         float64
+	deadAir      string // "ends" or "all" to find the cuts when encoding
+	burn         *burnIn
+	extraFilters string // appended to the built-in filters as written
+	crop         string // W:H:X:Y, aspect[:anchor] or "auto"
+	reframe      *reframeSettings
+	speed        float64
+	dedupe       dedupeSettings
+	deinterlace  deinterlaceSettings
+	denoise      denoiseSettings
+	stabilize    *stabilizeSettings
+	fpsMode      string
+	customOut    string
+	hw           hwType
+	container    string
+	passthrough  passthroughPolicy
+	audio        audioSettings
+	cover        bool
+	loudnorm     string           // loudnormPresets key, empty = off
+	streams      *streamSelection // nil = FFmpeg's default choice
+	// outputFile is the resolved final path; overwrite allows replacing it.
+	outputFile string
+	overwrite  bool
+	codecCfg   codecInfo
+	mode       outputMode
+	quality    int
+	crfSlider  int
+}
 
-		duration, _ := strconv.ParseFloat(info.Format.Duration, 64)
+func (m model) encodeOptions() encodeOptions {
+	opts := encodeOptions{
+		inputFile:    m.filePath,
+		joinFiles:    m.joinFiles,
+		split:        m.split,
+		naming:       m.naming,
+		targetMB:     m.targetSizeMB,
+		resInput:     m.targetRes,
+		fpsInput:     m.targetFPS,
+		cuts:         m.cuts,
+		snap:         m.snap,
+		fade:         m.fade,
+		deadAir:      m.deadAir,
+		burn:         m.burn,
+		extraFilters: m.extraVf,
+		crop:         m.crop,
+		reframe:      m.reframe,
+		speed:        m.speed,
+		dedupe:       m.dedupe,
+		deinterlace:  m.deinterlace,
+		denoise:      m.denoise,
+		stabilize:    m.stabilize,
+		fpsMode:      m.fpsMode,
+		customOut:    m.customOut,
+		hw:           hwCPU,
+		outputFile:   m.outputFile,
+		overwrite:    m.overwrite,
+		passthrough:  m.passthrough,
+		audio:        m.audio,
+		cover:        m.cover,
+		loudnorm:     m.loudnorm,
+		mode:         m.outputMode,
+		quality:      m.qualityLevel,
+		crfSlider:    m.crfLevel,
+	}
 
-		if trimStart != "" && trimEnd != "" {
-			s := parseDuration(trimStart)
-			e := parseDuration(trimEnd)
-			if e > s {
-				duration = e - s
-			}
+	if m.split != nil {
+		// collisions are checked on the first part
+		opts.part = partLabel(1)
+		if m.split.by == "chapters" && m.info != nil && len(m.info.Chapters) > 0 {
+			opts.part = chapterLabel(1, m.info.Chapters[0].Tags["title"])
+		} else if m.split.by == "scenes" {
+			opts.part = "scene01"
 		}
-
-		var outputFile string
-		var formatArgs []string
-		outputExt := codecCfg.Ext
-		switch mode {
-		case modeAPNG:
-			outputExt = ".png"
-		case modeAVIF:
-			outputExt = ".avif"
+	}
+	switch m.outputMode {
+	case modeGIF:
+		opts.codecCfg = codecInfo{Name: "GIF", Ext: ".gif"}
+	case modeAPNG:
+		opts.codecCfg = codecInfo{Name: "APNG", Ext: ".png"}
+	case modeAudio:
+		if opts.audio.codec == "" {
+			opts.audio.codec = audioCodecFromExt(m.customOut)
 		}
-
-		if customOut != "" {
-			outputFile = customOut
-			var fmtFlag string
-			switch mode {
-			case modeAVIF:
-				fmtFlag = "avif"
-			case modeAPNG:
-				fmtFlag = "apng"
-			default:
-				fmtFlag = strings.TrimPrefix(outputExt, ".")
-			}
-			formatArgs = []string{"-f", fmtFlag}
-		} else {
-			dir := filepath.Dir(inputFile)
-			name := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
-			outputFile = filepath.Join(dir, fmt.Sprintf("%s_compressed%s", name, outputExt))
+		if opts.audio.codec == "" {
+			opts.audio.codec = "opus"
 		}
-
-		// allow streaming
-		if codecCfg.Ext == ".mp4" {
-			formatArgs = append(formatArgs, "-movflags", "+faststart")
+		opts.codecCfg = codecInfo{Name: "Audio", FFmpegLib: audioEncoders[opts.audio.codec], Ext: audioOutputs[opts.audio.codec].Ext}
+	default:
+		opts.hw = hardwareOptions[m.selectedHW]
+		if options := codecOptions(opts.hw, m.outputMode); m.selectedCodec < len(options) {
+			opts.codecCfg = options[m.selectedCodec]
 		}
+	}
 
-		scaleFilter := buildScaleFilter(resInput)
+	if m.info != nil {
+		sel := m.streams
+		opts.streams = &sel
+	}
 
-		vfFilters := []string{}
-		if scaleFilter != "" {
-			vfFilters = append(vfFilters, scaleFilter)
+	if m.outputMode == modeVideo {
+		opts.container = m.container
+		if opts.container == "" {
+			opts.container = containerFromExt(m.customOut)
 		}
-		vfFilters = append(vfFilters, "mpdecimate") // remove duplicate frames
-		if fpsInput != "" {
-			vfFilters = append(vfFilters, fmt.Sprintf("fps=%s", fpsInput))
+		if opts.container == "" {
+			opts.container = strings.TrimPrefix(opts.codecCfg.Ext, ".")
 		}
+	}
+	return opts
+}
 
-		vfString := strings.Join(vfFilters, ",")
+// speedFactor is the playback speed, 1 if unchanged.
+func (o encodeOptions) speedFactor() float64 {
+	if o.speed <= 0 {
+		return 1
+	}
+	return o.speed
+}
 
-		trimArgs := []string{}
-		if trimStart != "" && trimEnd != "" {
-			trimArgs = []string{"-ss", trimStart, "-to", trimEnd}
+// outputExt is the extension of the file the job produces.
+func (o encodeOptions) outputExt() string {
+	switch o.mode {
+	case modeAPNG:
+		return ".png"
+	case modeAVIF:
+		return ".avif"
+	case modeAudio:
+		return audioOutputs[o.audio.codec].Ext
+	case modeVideo:
+		if c, ok := containers[o.container]; ok {
+			return c.Ext
 		}
+	}
+	return o.codecCfg.Ext
+}
 
-		switch mode {
-		case modeGIF:
-			gifVf := []string{}
-			if scaleFilter != "" {
-				gifVf = append(gifVf, scaleFilter)
+// codecOptions returns the encoders offered for hw, limited to AV1 in AVIF mode.
+func codecOptions(hw hwType, mode outputMode) []codecInfo {
+	options := encoderMap[hw]
+	if mode == modeAVIF {
+		var av1Options []codecInfo
+		for _, c := range options {
+			if strings.Contains(c.FFmpegLib, "av1") {
+				av1Options = append(av1Options, c)
 			}
-			gifVf = append(gifVf, "mpdecimate")
+		}
+		options = av1Options
+	}
+	return options
+}
 
-			if fpsInput != "" {
-				gifVf = append(gifVf, fmt.Sprintf("fps=%s", fpsInput))
+// findCodec looks up an FFmpeg encoder name and returns its position in the
+// hardware and codec menus.
+func findCodec(lib string, mode outputMode) (hwIdx, codecIdx int, ok bool) {
+	for i, hw := range hardwareOptions {
+		for j, c := range codecOptions(hw, mode) {
+			if c.FFmpegLib == lib {
+				return i, j, true
 			}
+		}
+	}
+	return 0, 0, false
+}
 
-			gifVfStr := strings.Join(gifVf, ",")
+// startEncoding runs the job described by opts in the background, reporting
+// progress on progressChan and closing it when done.
+func startEncoding(opts encodeOptions, progressChan chan progressMsg) tea.Cmd {
+	return func() tea.Msg {
+		defer close(progressChan)
+		if opts.split != nil {
+			return runSplit(opts, progressChan)
+		}
+		return encodeJob(opts, progressChan)
+	}
+}
 
-			paletteFile := filepath.Join(os.TempDir(), fmt.Sprintf("palette_%d.png", time.Now().UnixNano()))
-			defer os.Remove(paletteFile)
+// encodeJob probes the input and encodes it into one output file.
+func encodeJob(opts encodeOptions, progressChan chan progressMsg) workDoneMsg {
+	inputFile := opts.inputFile
+	targetMB := opts.targetMB
+	customOut := opts.customOut
+	hw := opts.hw
+	codecCfg := opts.codecCfg
+	mode := opts.mode
+	quality := opts.quality
+	crfSlider := opts.crfSlider
+
+	logPath := newJobLog(inputFile)
+
+	if len(opts.joinFiles) > 1 {
+		joined, err := joinInputs(opts, progressChan, logPath)
+		if err != nil {
+			return workDoneMsg{err: err}
+		}
+		defer os.Remove(joined)
+		// from here on the joined file is the input
+		inputFile, opts.inputFile, opts.streams = joined, joined, nil
+	}
 
-			progressChan <- progressMsg{line: "Generating Palette...", progress: 0.1}
+	progressChan <- progressMsg{line: "Analyzing file...", progress: 0}
+	info, err := probeFile(inputFile, logPath)
+	if err != nil {
+		return workDoneMsg{err: err}
+	}
+	progressChan <- progressMsg{kind: evProbe, probe: info}
 
-			palFilter := gifVfStr
-			if palFilter != "" {
-				palFilter += ","
-			}
-			palFilter += "palettegen"
-			palArgs := []string{"-y"}
-			palArgs = append(palArgs, trimArgs...)
-			palArgs = append(palArgs, "-i", inputFile, "-vf", palFilter, paletteFile)
+	if opts.streams == nil {
+		sel := defaultStreams(info, nil, nil, nil)
+		opts.streams = &sel
+	}
+	sel := *opts.streams
+	if sel.video < 0 && mode != modeAudio {
+		return workDoneMsg{err: fmt.Errorf("no video stream selected")}
+	}
+	opts.trimDeadAir(info, progressChan, logPath)
 
-			if err := runFFmpeg(palArgs, progressChan, duration, "GIF Palette"); err != nil {
-				return workDoneMsg{err: err}
-			}
+	snapped := false
+	if v := info.stream(sel.video); opts.snap && len(opts.cuts) > 0 && v != nil && mode != modeAudio {
+		total, _ := strconv.ParseFloat(info.Format.Duration, 64)
+		keyframes, err := probeKeyframes(inputFile, v.Index, total, progressChan, logPath)
+		if err != nil {
+			progressChan <- progressMsg{kind: evWarning, line: "could not find keyframes, cutting frame-accurately: " + err.Error()}
+		} else {
+			opts.cuts, snapped = snapRanges(opts.cuts, keyframes, total), true
+			progressChan <- progressMsg{line: "Snapped to keyframes: " + formatRanges(opts.cuts)}
+		}
+	}
 
-			progressChan <- progressMsg{line: "Encoding GIF...", progress: 0.5}
+	// span is the part of the source the job reads, duration what comes
+	// out of it after a speed change
+	span := jobDuration(opts, info)
+	duration := span / opts.speedFactor()
 
-			filterComplex := fmt.Sprintf("[0:v]%s[x];[x][1:v]paletteuse", gifVfStr)
-			if gifVfStr == "" {
-				filterComplex = "[0:v]fifo[x];[x][1:v]paletteuse"
-			}
+	// encode next to the final file, renamed into place by finishWork
+	if err := os.MkdirAll(filepath.Dir(opts.outputFile), 0o755); err != nil {
+		return workDoneMsg{err: fmt.Errorf("could not create the output folder: %w", err)}
+	}
+	outputFile := tempOutputPath(opts.outputFile)
+	defer os.Remove(outputFile)
+
+	var formatArgs, subArgs []string
+	var audioCodec string
+	if mode == modeVideo {
+		ctr := containers[opts.container]
+		var warnings []string
+		audioCodec, warnings, err = checkContainer(ctr, codecCfg.FFmpegLib, opts.audio.codec)
+		if err != nil {
+			return workDoneMsg{err: err}
+		}
+		if len(sel.subs) > 0 && (len(opts.cuts) > 1 || opts.speedFactor() != 1) {
+			// only burned-in subtitles are retimed to the output
+			warnings = append(warnings, "soft subtitles would be out of sync after a cut list or speed change, leaving them out; use -burn to keep one")
+			sel.subs = nil
+		}
+		if subArgs, err = subtitleArgs(info, sel, ctr); err != nil {
+			return workDoneMsg{err: err}
+		}
+		for _, w := range warnings {
+			progressChan <- progressMsg{kind: evWarning, line: w}
+		}
 
-			encArgs := []string{"-y"}
-			encArgs = append(encArgs, trimArgs...)
-			encArgs = append(encArgs,
-				"-i", inputFile, "-i", paletteFile,
-				"-lavfi", filterComplex,
-			)
-			encArgs = append(encArgs, formatArgs...)
-			encArgs = append(encArgs, outputFile)
+		formatArgs = []string{"-f", ctr.Format}
+		// allow streaming
+		if ctr.Faststart {
+			formatArgs = append(formatArgs, "-movflags", "+faststart")
+		}
+	} else if customOut != "" {
+		var fmtFlag string
+		switch mode {
+		case modeAVIF:
+			fmtFlag = "avif"
+		case modeAPNG:
+			fmtFlag = "apng"
+		default:
+			fmtFlag = strings.TrimPrefix(opts.outputExt(), ".")
+		}
+		formatArgs = []string{"-f", fmtFlag}
+	}
 
-			fullCmd := fmt.Sprintf("ffmpeg %s", strings.Join(encArgs, " "))
-			progressChan <- progressMsg{debugCmd: fullCmd}
+	trimArgs := opts.trimArgs()
 
-			if err := runFFmpeg(encArgs, progressChan, duration, "GIF Encode"); err != nil {
+	streamCopy := false
+	if snapped && len(opts.cuts) == 1 {
+		if reason := snapCopyBlocker(opts, info, span); reason != "" {
+			progressChan <- progressMsg{line: "Encoding the snapped range: " + reason}
+		} else {
+			streamCopy = true
+		}
+	}
+	if reason := passthroughReason(opts, info, span); reason != "" && !streamCopy {
+		switch opts.passthrough {
+		case passSkip:
+			return workDoneMsg{outputFile: opts.outputFile, skipReason: reason}
+		case passCopy:
+			streamCopy = true
+		case passAsk:
+			progressChan <- progressMsg{kind: evWarning, line: reason + "; pass -passthrough copy or skip to avoid re-encoding"}
+		}
+	}
+	if streamCopy {
+		args, err := remuxArgs(inputFile, info, sel, containers[opts.container], trimArgs, formatArgs, outputFile)
+		if err != nil {
+			progressChan <- progressMsg{kind: evWarning, line: err.Error() + ", encoding instead"}
+		} else {
+			if err := runFFmpeg(args, progressChan, duration, "Stream Copy", logPath); err != nil {
 				return workDoneMsg{err: err}
 			}
+			return finishWork(outputFile, opts, duration, logPath)
+		}
+	}
 
-			return finishWork(outputFile)
-
-		case modeAPNG:
-			progressChan <- progressMsg{line: "Encoding APNG...", progress: 0.1}
-			apngVf := []string{}
-			if scaleFilter != "" {
-				apngVf = append(apngVf, scaleFilter)
-			}
-			apngVf = append(apngVf, "mpdecimate")
-			if fpsInput != "" {
-				apngVf = append(apngVf, fmt.Sprintf("fps=%s", fpsInput))
-			}
-			vfString := strings.Join(apngVf, ",")
-			encArgs := []string{"-y"}
-			encArgs = append(encArgs, trimArgs...)
-			encArgs = append(encArgs, "-i", inputFile)
-			if vfString != "" {
-				encArgs = append(encArgs, "-vf", vfString)
-			}
-			encArgs = append(encArgs, "-c:v", "apng", "-plays", "0", "-f", "apng")
-			encArgs = append(encArgs, formatArgs...)
-			encArgs = append(encArgs, outputFile)
-			fullCmd := fmt.Sprintf("ffmpeg %s", strings.Join(encArgs, " "))
-			progressChan <- progressMsg{debugCmd: fullCmd}
-			if err := runFFmpeg(encArgs, progressChan, duration, "APNG Encode"); err != nil {
+	if mode != modeAudio {
+		opts.deinterlace = resolveDeinterlace(opts, info, sel, progressChan, logPath)
+		opts.dedupe.mode = resolveDedupe(opts, info, sel, span, progressChan, logPath)
+	}
+	if v := info.stream(sel.video); opts.crop == "auto" && v != nil {
+		start, length := opts.sourceWindow(info)
+		rect, err := detectCrop(inputFile, v.Index, v.Width, v.Height, start, length, progressChan, logPath)
+		switch {
+		case err != nil:
+			progressChan <- progressMsg{kind: evWarning, line: "crop detection failed, not cropping: " + err.Error()}
+			opts.crop = ""
+		case rect == nil:
+			progressChan <- progressMsg{line: "No black bars found"}
+			opts.crop = ""
+		default:
+			progressChan <- progressMsg{line: "Cropping black bars: " + rect.String()}
+			opts.crop = rect.String()
+		}
+	}
+	if d := opts.denoise; d.grain && mode != modeAudio && !d.synthesizes(codecCfg.FFmpegLib) {
+		progressChan <- progressMsg{kind: evWarning, line: fmt.Sprintf("film grain synthesis needs libsvtav1 or libaom-av1, denoising with %s instead", d.denoiser)}
+	}
+	if opts.stabilize != nil && mode != modeAudio && sel.video >= 0 {
+		stab := *opts.stabilize
+		if hasVidstab() {
+			trf, err := analyzeShake(opts, info, sel, span, progressChan, logPath)
+			if err != nil {
 				return workDoneMsg{err: err}
 			}
-			return finishWork(outputFile)
+			defer os.Remove(trf)
+			stab.transforms = trf
+		} else {
+			progressChan <- progressMsg{line: "This FFmpeg build has no vidstab, stabilizing with deshake"}
 		}
+		opts.stabilize = &stab
+	}
+	pipeline, err := videoPipeline(opts, info)
+	if err != nil {
+		return workDoneMsg{err: err}
+	}
+	frameArgs := frameRateArgs(opts.fpsMode, opts.dedupe.mode == dedupeOn)
 
-		// video & avif mode
-		hasAudio := false
-		for _, s := range info.Streams {
-			if s.CodecType == "audio" {
-				hasAudio = true
-				break
-			}
+	if speed := opts.speedFactor(); speed != 1 && len(sel.audio) > 0 && !opts.audio.mute {
+		if speed > speedAudioMax && mode != modeAudio {
+			progressChan <- progressMsg{line: fmt.Sprintf("Dropping the audio at %gx speed", speed)}
+			opts.audio.mute = true
+		} else {
+			opts.audio.tempo = atempoChain(speed)
 		}
+	}
+	if _, edit := opts.editFilters(info); len(edit) > 0 {
+		opts.audio.edit = filterPipeline{edit}.chain()
+	}
 
-		isCRFMode := targetMB <= 0
-		var videoKBit int
-
-		if !isCRFMode {
-			targetBits := targetMB * 8388608 // 8 * 1024 * 1024
-			audioRate := 0.0
-			if hasAudio {
-				audioRate = 128 * 1024
+	// two-pass loudness normalization: measure now, apply while encoding
+	var loudness *loudnessResult
+	loudnessTrack := 0 // output audio track the summary reports
+	if preset, ok := loudnormPresets[opts.loudnorm]; ok && (mode == modeVideo || mode == modeAudio) && len(sel.audio) > 0 && !opts.audio.mute {
+		tracks := sel.audio
+		if mode == modeAudio {
+			tracks = tracks[:1] // audio mode keeps the first track
+		}
+		// each track is measured and normalized on its own
+		opts.audio.filters = make([]string, len(tracks))
+		for i, idx := range tracks {
+			stage := "Loudness Analysis"
+			if len(tracks) > 1 {
+				stage = fmt.Sprintf("Loudness Analysis (track %d)", i+1)
+			}
+			measured, err := measureLoudness(inputFile, trimArgs, []string{"-map", fmt.Sprintf("0:%d", idx)}, preset, progressChan, span, stage, logPath)
+			if err != nil {
+				return workDoneMsg{err: err}
+			}
+			if math.IsInf(measured.I, 0) {
+				progressChan <- progressMsg{kind: evWarning, line: fmt.Sprintf("audio track %d is silent, skipping loudness normalization for it", i+1)}
+				continue
 			}
-			totalRate := targetBits / duration
-			videoRate := (totalRate - audioRate) * 0.95
-			if videoRate < 50*1024 {
-				videoRate = 50 * 1024
+			opts.audio.filters[i] = preset.filter(measured)
+			if loudness == nil {
+				// the summary reports the first normalized track
+				loudness = &loudnessResult{Target: preset.I, Before: measured.I}
+				loudnessTrack = i
 			}
-			videoKBit = int(videoRate / 1024)
 		}
-
-		isCPU := hw == hwCPU
-
-		var audioArgs []string
-		if hasAudio && mode != modeAVIF {
-			if codecCfg.Ext == ".mp4" {
-				audioArgs = []string{"-c:a", "aac", "-b:a", "128k"}
+	}
+	// finishNormalized measures the encoded file for the summary before
+	// committing it.
+	finishNormalized := func() workDoneMsg {
+		if loudness != nil {
+			loudness.After = math.NaN()
+			if measured, err := measureLoudness(outputFile, nil, []string{"-map", fmt.Sprintf("0:a:%d", loudnessTrack)}, loudnormPresets[opts.loudnorm], progressChan, duration, "Loudness Check", logPath); err == nil {
+				loudness.After = measured.I
 			} else {
-				audioArgs = []string{"-c:a", "libopus", "-b:a", "128k"}
+				progressChan <- progressMsg{kind: evWarning, line: "could not measure the output loudness"}
 			}
-		} else {
-			audioArgs = []string{"-an"}
 		}
+		done := finishWork(outputFile, opts, duration, logPath)
+		done.loudness = loudness
+		return done
+	}
+
+	switch mode {
+	case modeGIF:
+		paletteFile := filepath.Join(os.TempDir(), fmt.Sprintf("palette_%d.png", time.Now().UnixNano()))
+		defer os.Remove(paletteFile)
+
+		progressChan <- progressMsg{line: "Generating Palette...", progress: 0.1}
+
+		palArgs := []string{"-y"}
+		palArgs = append(palArgs, trimArgs...)
+		palArgs = append(palArgs, "-i", inputFile)
+		palArgs = append(palArgs, sel.mapArgs(false)...)
+		palArgs = append(palArgs, pipeline.with(newFilter(stageFormat, "palettegen")).vfArgs()...)
+		palArgs = append(palArgs, paletteFile)
+
+		if err := runFFmpeg(palArgs, progressChan, duration, "GIF Palette", logPath); err != nil {
+			return workDoneMsg{err: err}
+		}
+
+		progressChan <- progressMsg{line: "Encoding GIF...", progress: 0.5}
 
-		filterArgs := []string{}
-		if vfString != "" {
-			filterArgs = []string{"-vf", vfString}
+		encArgs := []string{"-y"}
+		encArgs = append(encArgs, trimArgs...)
+		encArgs = append(encArgs, "-i", inputFile, "-i", paletteFile)
+		encArgs = append(encArgs, lavfiArgs(
+			pipeline.labelled(fmt.Sprintf("0:%d", sel.video), "x"),
+			"[x][1:v]paletteuse",
+		)...)
+		encArgs = append(encArgs, frameArgs...)
+		encArgs = append(encArgs, formatArgs...)
+		encArgs = append(encArgs, outputFile)
+
+		if err := runFFmpeg(encArgs, progressChan, duration, "GIF Encode", logPath); err != nil {
+			return workDoneMsg{err: err}
 		}
 
-		if isCPU {
-			passLog := filepath.Join(os.TempDir(), fmt.Sprintf("pass_%d", time.Now().UnixNano()))
+		return finishWork(outputFile, opts, duration, logPath)
 
-			extraArgs := []string{"-pix_fmt", "yuv420p"}
-			if mode == modeAVIF {
-				extraArgs = append(extraArgs, "-still-picture", "0")
-			}
-			switch codecCfg.FFmpegLib {
-			case "libvpx-vp9":
-				vp9Speeds := []string{"8", "7", "6", "4", "1"}
-				extraArgs = append(extraArgs, "-speed", vp9Speeds[quality], "-row-mt", "1", "-tile-columns", "2")
-				if isCRFMode {
-					crf := 20 + int(float64(crfSlider)*2.5) // 20-45
-					extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf), "-b:v", "0")
-				}
-			case "libaom-av1":
-				aomSpeeds := []string{"8", "7", "6", "4", "3"}
-				extraArgs = append(extraArgs, "-cpu-used", aomSpeeds[quality], "-row-mt", "1", "-tiles", "2x2")
-				if isCRFMode {
-					crf := 20 + (crfSlider * 3) // 20-50
-					extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
-				}
-			case "libsvtav1":
-				svtPresets := []string{"12", "10", "8", "6", "4"}
-				extraArgs = append(extraArgs, "-preset", svtPresets[quality])
-				if isCRFMode {
-					crf := 20 + (crfSlider * 3) // 20-50
-					extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
-				}
-			case "librav1e":
-				ravSpeeds := []string{"10", "8", "6", "4", "2"}
-				extraArgs = append(extraArgs, "-speed", ravSpeeds[quality])
-				if isCRFMode {
-					crf := 60 + (crfSlider * 8) // 60-140
-					extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
-				}
-			case "libx264":
-				x264Presets := []string{"ultrafast", "veryfast", "faster", "medium", "veryslow"}
-				extraArgs = append(extraArgs, "-preset", x264Presets[quality])
-				if isCRFMode {
-					crf := 18 + int(float64(crfSlider)*1.5) // 18-33
-					extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
-				}
-			case "libx265":
-				x265Presets := []string{"ultrafast", "veryfast", "fast", "medium", "veryslow"}
-				extraArgs = append(extraArgs, "-preset", x265Presets[quality])
-				if isCRFMode {
-					crf := 20 + int(float64(crfSlider)*1.6) // 20-36
-					extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
-				}
-			default:
-				extraArgs = append(extraArgs, "-preset", "medium")
-			}
+	case modeAudio:
+		kbit, warnings := audioOnlyBitrate(opts, opts.audio.codec, duration)
+		args, argWarnings, err := audioOnlyArgs(opts, info, trimArgs, kbit, outputFile)
+		if err != nil {
+			return workDoneMsg{err: err}
+		}
+		for _, w := range append(warnings, argWarnings...) {
+			progressChan <- progressMsg{kind: evWarning, line: w}
+		}
+		if err := runFFmpeg(args, progressChan, duration, "Audio Encode", logPath); err != nil {
+			return workDoneMsg{err: err}
+		}
+		return finishNormalized()
 
-			if isCRFMode {
-				// single pass (CRF)
-				args := []string{"-y"}
-				args = append(args, trimArgs...)
-				args = append(args, "-i", inputFile, "-c:v", codecCfg.FFmpegLib)
-				args = append(args, extraArgs...)
-				args = append(args, filterArgs...)
-				args = append(args, audioArgs...)
-				args = append(args, formatArgs...)
-				args = append(args, outputFile)
-
-				fullCmd := fmt.Sprintf("ffmpeg %s", strings.Join(args, " "))
-				progressChan <- progressMsg{debugCmd: fullCmd}
-
-				if err := runFFmpeg(args, progressChan, duration, "Encoding (CRF)"); err != nil {
-					return workDoneMsg{err: err}
-				}
-			} else {
-				nullOut := "/dev/null"
-				if runtime.GOOS == "windows" {
-					nullOut = "NUL"
-				}
+	case modeAPNG:
+		progressChan <- progressMsg{line: "Encoding APNG...", progress: 0.1}
+		encArgs := []string{"-y"}
+		encArgs = append(encArgs, trimArgs...)
+		encArgs = append(encArgs, "-i", inputFile)
+		encArgs = append(encArgs, sel.mapArgs(false)...)
+		encArgs = append(encArgs, pipeline.vfArgs()...)
+		encArgs = append(encArgs, frameArgs...)
+		encArgs = append(encArgs, "-c:v", "apng", "-plays", "0", "-f", "apng")
+		encArgs = append(encArgs, formatArgs...)
+		encArgs = append(encArgs, outputFile)
+		if err := runFFmpeg(encArgs, progressChan, duration, "APNG Encode", logPath); err != nil {
+			return workDoneMsg{err: err}
+		}
+		return finishWork(outputFile, opts, duration, logPath)
+	}
 
-				// pass 1
-				p1 := []string{"-y"}
-				p1 = append(p1, trimArgs...)
-				p1 = append(p1, "-i", inputFile, "-c:v", codecCfg.FFmpegLib, "-b:v", fmt.Sprintf("%dk", videoKBit), "-pass", "1", "-passlogfile", passLog, "-an")
-				p1 = append(p1, filterArgs...)
-				p1 = append(p1, extraArgs...)
-				p1 = append(p1, "-f", "null", nullOut)
+	// video & avif mode
+	hasAudio := len(sel.audio) > 0 && mode != modeAVIF && !opts.audio.mute
+	audioTracks := 0
+	if hasAudio {
+		audioTracks = len(sel.audio)
+	}
 
-				fullCmd1 := fmt.Sprintf("ffmpeg %s", strings.Join(p1, " "))
-				progressChan <- progressMsg{debugCmd: fullCmd1}
+	isCRFMode := targetMB <= 0
+	var videoKBit int
 
-				if err := runFFmpeg(p1, progressChan, duration, "Pass 1 (Analysis)"); err != nil {
-					return workDoneMsg{err: err}
-				}
+	totalRate := 0.0
+	if !isCRFMode {
+		totalRate = targetMB * 8388608 / duration // 8 * 1024 * 1024
+	}
+	audioKBit := opts.audio.trackBitrate(audioCodec, totalRate, audioTracks)
 
-				// pass 2
-				p2 := []string{"-y"}
-				p2 = append(p2, trimArgs...)
-				p2 = append(p2, "-i", inputFile, "-c:v", codecCfg.FFmpegLib, "-b:v", fmt.Sprintf("%dk", videoKBit), "-pass", "2", "-passlogfile", passLog)
-				p2 = append(p2, filterArgs...)
-				p2 = append(p2, extraArgs...)
-				p2 = append(p2, audioArgs...)
-				p2 = append(p2, formatArgs...)
-				p2 = append(p2, outputFile)
-
-				fullCmd2 := fmt.Sprintf("ffmpeg %s", strings.Join(p2, " "))
-				progressChan <- progressMsg{debugCmd: fullCmd2}
-
-				if err := runFFmpeg(p2, progressChan, duration, "Pass 2 (Encoding)"); err != nil {
-					return workDoneMsg{err: err}
-				}
-				_ = os.Remove(passLog + "-0.log")
-				_ = os.Remove(passLog + ".log")
-				_ = os.Remove(passLog + "-0.log.mbtree")
+	// keep the source audio if the container takes it and it fits the budget
+	copyAudioRate := 0.0
+	if audioTracks == 1 && opts.audio.keepsSource() {
+		copyAudioRate = copyableAudio(info.stream(sel.audio[0]), containers[opts.container], float64(audioKBit)*1000)
+	}
+
+	if !isCRFMode {
+		audioRate := 0.0
+		switch {
+		case copyAudioRate > 0:
+			audioRate = copyAudioRate
+		case hasAudio && slices.Contains(losslessCodecs, audioCodec):
+			for _, idx := range sel.audio {
+				audioRate += opts.audio.losslessRate(info.stream(idx))
 			}
+		case hasAudio:
+			audioRate = float64(audioKBit*audioTracks) * 1000
+		}
+		videoRate := (totalRate - audioRate) * 0.95
+		if videoRate < 50*1000 {
+			videoRate = 50 * 1000
+		}
+		videoKBit = int(videoRate / 1000)
+	}
+
+	isCPU := hw == hwCPU
+
+	var audioArgs []string
+	if copyAudioRate > 0 {
+		audioArgs = []string{"-c:a", "copy"}
+	} else if hasAudio {
+		audioArgs = opts.audio.args(audioEncoders[audioCodec], audioKBit, audioTracks)
+	} else {
+		audioArgs = []string{"-an"}
+	}
 
+	filterArgs := append(pipeline.vfArgs(), frameArgs...)
+
+	if isCPU {
+		passLog := filepath.Join(os.TempDir(), fmt.Sprintf("pass_%d", time.Now().UnixNano()))
+
+		extraArgs := []string{"-pix_fmt", "yuv420p"}
+		if mode == modeAVIF {
+			extraArgs = append(extraArgs, "-still-picture", "0")
+		}
+		switch codecCfg.FFmpegLib {
+		case "libvpx-vp9":
+			vp9Speeds := []string{"8", "7", "6", "4", "1"}
+			extraArgs = append(extraArgs, "-speed", vp9Speeds[quality], "-row-mt", "1", "-tile-columns", "2")
+			if isCRFMode {
+				crf := 20 + int(float64(crfSlider)*2.5) // 20-45
+				extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf), "-b:v", "0")
+			}
+		case "libaom-av1":
+			aomSpeeds := []string{"8", "7", "6", "4", "3"}
+			extraArgs = append(extraArgs, "-cpu-used", aomSpeeds[quality], "-row-mt", "1", "-tiles", "2x2")
+			if isCRFMode {
+				crf := 20 + (crfSlider * 3) // 20-50
+				extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
+			}
+		case "libsvtav1":
+			svtPresets := []string{"12", "10", "8", "6", "4"}
+			extraArgs = append(extraArgs, "-preset", svtPresets[quality])
+			if isCRFMode {
+				crf := 20 + (crfSlider * 3) // 20-50
+				extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
+			}
+		case "librav1e":
+			ravSpeeds := []string{"10", "8", "6", "4", "2"}
+			extraArgs = append(extraArgs, "-speed", ravSpeeds[quality])
+			if isCRFMode {
+				crf := 60 + (crfSlider * 8) // 60-140
+				extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
+			}
+		case "libx264":
+			x264Presets := []string{"ultrafast", "veryfast", "faster", "medium", "veryslow"}
+			extraArgs = append(extraArgs, "-preset", x264Presets[quality])
+			if isCRFMode {
+				crf := 18 + int(float64(crfSlider)*1.5) // 18-33
+				extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
+			}
+		case "libx265":
+			x265Presets := []string{"ultrafast", "veryfast", "fast", "medium", "veryslow"}
+			extraArgs = append(extraArgs, "-preset", x265Presets[quality])
+			if isCRFMode {
+				crf := 20 + int(float64(crfSlider)*1.6) // 20-36
+				extraArgs = append(extraArgs, "-crf", strconv.Itoa(crf))
+			}
+		default:
+			extraArgs = append(extraArgs, "-preset", "medium")
+		}
+		extraArgs = append(extraArgs, opts.denoise.encoderArgs(codecCfg.FFmpegLib)...)
+
+		if isCRFMode {
+			// single pass (CRF)
+			args := []string{"-y"}
+			args = append(args, trimArgs...)
+			args = append(args, "-i", inputFile)
+			args = append(args, sel.mapArgs(hasAudio)...)
+			args = append(args, "-c:v", codecCfg.FFmpegLib)
+			args = append(args, extraArgs...)
+			args = append(args, filterArgs...)
+			args = append(args, audioArgs...)
+			args = append(args, subArgs...)
+			args = append(args, formatArgs...)
+			args = append(args, outputFile)
+
+			if err := runFFmpeg(args, progressChan, duration, "Encoding (CRF)", logPath); err != nil {
+				return workDoneMsg{err: err}
+			}
 		} else {
-			extraArgs := []string{"-pix_fmt", "yuv420p"}
-			if mode == modeAVIF {
-				extraArgs = append(extraArgs, "-still-picture", "0")
+			nullOut := "/dev/null"
+			if runtime.GOOS == "windows" {
+				nullOut = "NUL"
 			}
-			hwQuality := 19 + int(float64(crfSlider)*1.5) // 19-34
 
-			if strings.Contains(codecCfg.FFmpegLib, "nvenc") {
-				nvPresets := []string{"p1", "p2", "p4", "p6", "p7"}
-				extraArgs = append(extraArgs, "-preset", nvPresets[quality])
-				if isCRFMode {
-					extraArgs = append(extraArgs, "-rc", "vbr", "-cq", strconv.Itoa(hwQuality))
-				} else {
-					extraArgs = append(extraArgs, "-rc", "vbr", "-cq", "0")
-				}
-			} else if strings.Contains(codecCfg.FFmpegLib, "amf") {
-				amfPresets := []string{"speed", "speed", "balanced", "quality", "quality"}
-				if strings.Contains(codecCfg.FFmpegLib, "av1") {
-					amfPresets = []string{"speed", "balanced", "quality", "high_quality", "high_quality"}
-				}
-				extraArgs = append(extraArgs, "-quality", amfPresets[quality])
-				if isCRFMode {
-					extraArgs = append(extraArgs, "-rc", "cqp", "-qp_i", strconv.Itoa(hwQuality), "-qp_p", strconv.Itoa(hwQuality))
-				}
-			} else if strings.Contains(codecCfg.FFmpegLib, "qsv") {
-				qsvPresets := []string{"veryfast", "faster", "balanced", "slow", "veryslow"}
-				extraArgs = append(extraArgs, "-preset", qsvPresets[quality])
-				if isCRFMode {
-					extraArgs = append(extraArgs, "-global_quality", strconv.Itoa(hwQuality))
-				}
+			// pass 1
+			p1 := []string{"-y"}
+			p1 = append(p1, trimArgs...)
+			p1 = append(p1, "-i", inputFile)
+			p1 = append(p1, sel.mapArgs(false)...)
+			p1 = append(p1, "-c:v", codecCfg.FFmpegLib, "-b:v", fmt.Sprintf("%dk", videoKBit), "-pass", "1", "-passlogfile", passLog, "-an")
+			p1 = append(p1, filterArgs...)
+			p1 = append(p1, extraArgs...)
+			p1 = append(p1, "-f", "null", nullOut)
+
+			if err := runFFmpeg(p1, progressChan, duration, "Pass 1 (Analysis)", logPath); err != nil {
+				return workDoneMsg{err: err}
 			}
 
-			cmdArgs := []string{"-y", "-hwaccel", "auto"}
-			cmdArgs = append(cmdArgs, trimArgs...)
-			cmdArgs = append(cmdArgs, "-i", inputFile, "-c:v", codecCfg.FFmpegLib)
-			if !isCRFMode {
-				cmdArgs = append(cmdArgs,
-					"-b:v", fmt.Sprintf("%dk", videoKBit),
-					"-maxrate", fmt.Sprintf("%dk", videoKBit),
-					"-bufsize", fmt.Sprintf("%dk", videoKBit*2),
-				)
+			// pass 2
+			p2 := []string{"-y"}
+			p2 = append(p2, trimArgs...)
+			p2 = append(p2, "-i", inputFile)
+			p2 = append(p2, sel.mapArgs(hasAudio)...)
+			p2 = append(p2, "-c:v", codecCfg.FFmpegLib, "-b:v", fmt.Sprintf("%dk", videoKBit), "-pass", "2", "-passlogfile", passLog)
+			p2 = append(p2, filterArgs...)
+			p2 = append(p2, extraArgs...)
+			p2 = append(p2, audioArgs...)
+			p2 = append(p2, subArgs...)
+			p2 = append(p2, formatArgs...)
+			p2 = append(p2, outputFile)
+
+			if err := runFFmpeg(p2, progressChan, duration, "Pass 2 (Encoding)", logPath); err != nil {
+				return workDoneMsg{err: err}
 			}
-			cmdArgs = append(cmdArgs, filterArgs...)
-			cmdArgs = append(cmdArgs, extraArgs...)
-			cmdArgs = append(cmdArgs, audioArgs...)
-			cmdArgs = append(cmdArgs, formatArgs...)
-			cmdArgs = append(cmdArgs, outputFile)
+			_ = os.Remove(passLog + "-0.log")
+			_ = os.Remove(passLog + ".log")
+			_ = os.Remove(passLog + "-0.log.mbtree")
+		}
 
-			fullCmd := fmt.Sprintf("ffmpeg %s", strings.Join(cmdArgs, " "))
-			progressChan <- progressMsg{debugCmd: fullCmd}
+	} else {
+		extraArgs := []string{"-pix_fmt", "yuv420p"}
+		if mode == modeAVIF {
+			extraArgs = append(extraArgs, "-still-picture", "0")
+		}
+		hwQuality := 19 + int(float64(crfSlider)*1.5) // 19-34
 
-			if err := runFFmpeg(cmdArgs, progressChan, duration, "GPU Encoding"); err != nil {
-				return workDoneMsg{err: err}
+		if strings.Contains(codecCfg.FFmpegLib, "nvenc") {
+			nvPresets := []string{"p1", "p2", "p4", "p6", "p7"}
+			extraArgs = append(extraArgs, "-preset", nvPresets[quality])
+			if isCRFMode {
+				extraArgs = append(extraArgs, "-rc", "vbr", "-cq", strconv.Itoa(hwQuality))
+			} else {
+				extraArgs = append(extraArgs, "-rc", "vbr", "-cq", "0")
+			}
+		} else if strings.Contains(codecCfg.FFmpegLib, "amf") {
+			amfPresets := []string{"speed", "speed", "balanced", "quality", "quality"}
+			if strings.Contains(codecCfg.FFmpegLib, "av1") {
+				amfPresets = []string{"speed", "balanced", "quality", "high_quality", "high_quality"}
+			}
+			extraArgs = append(extraArgs, "-quality", amfPresets[quality])
+			if isCRFMode {
+				extraArgs = append(extraArgs, "-rc", "cqp", "-qp_i", strconv.Itoa(hwQuality), "-qp_p", strconv.Itoa(hwQuality))
+			}
+		} else if strings.Contains(codecCfg.FFmpegLib, "qsv") {
+			qsvPresets := []string{"veryfast", "faster", "balanced", "slow", "veryslow"}
+			extraArgs = append(extraArgs, "-preset", qsvPresets[quality])
+			if isCRFMode {
+				extraArgs = append(extraArgs, "-global_quality", strconv.Itoa(hwQuality))
 			}
 		}
 
-		return finishWork(outputFile)
+		cmdArgs := []string{"-y", "-hwaccel", "auto"}
+		cmdArgs = append(cmdArgs, trimArgs...)
+		cmdArgs = append(cmdArgs, "-i", inputFile)
+		cmdArgs = append(cmdArgs, sel.mapArgs(hasAudio)...)
+		cmdArgs = append(cmdArgs, "-c:v", codecCfg.FFmpegLib)
+		if !isCRFMode {
+			cmdArgs = append(cmdArgs,
+				"-b:v", fmt.Sprintf("%dk", videoKBit),
+				"-maxrate", fmt.Sprintf("%dk", videoKBit),
+				"-bufsize", fmt.Sprintf("%dk", videoKBit*2),
+			)
+		}
+		cmdArgs = append(cmdArgs, filterArgs...)
+		cmdArgs = append(cmdArgs, extraArgs...)
+		cmdArgs = append(cmdArgs, audioArgs...)
+		cmdArgs = append(cmdArgs, subArgs...)
+		cmdArgs = append(cmdArgs, formatArgs...)
+		cmdArgs = append(cmdArgs, outputFile)
+
+		if err := runFFmpeg(cmdArgs, progressChan, duration, "GPU Encoding", logPath); err != nil {
+			return workDoneMsg{err: err}
+		}
+	}
+
+	return finishNormalized()
+}
+
+// jobDuration is the length of the part of the source the job covers, taking
+// trimming and cuts into account. It is in source seconds; callers divide by
+// speedFactor for the output's length.
+func jobDuration(opts encodeOptions, info *FFProbeOutput) float64 {
+	duration, _ := strconv.ParseFloat(info.Format.Duration, 64)
+
+	if kept := keptDuration(opts.cuts); kept > 0 {
+		duration = kept
 	}
+	return duration
 }
 
-func finishWork(path string) workDoneMsg {
+func finishWork(tmpPath string, opts encodeOptions, duration float64, logPath string) workDoneMsg {
+	path, err := commitOutput(tmpPath, opts.outputFile, opts.overwrite)
+	if err != nil {
+		return workDoneMsg{outputFile: opts.outputFile, logPath: logPath, err: err}
+	}
+
 	fi, err := os.Stat(path)
 	sizeStr := "Unknown"
+	var sizeBytes int64
 	if err == nil {
-		mb := float64(fi.Size()) / 1024 / 1024
+		sizeBytes = fi.Size()
+		mb := float64(sizeBytes) / 1024 / 1024
 		sizeStr = fmt.Sprintf("%.2f MB", mb)
 	}
-	return workDoneMsg{outputFile: path, finalSize: sizeStr, err: nil}
+	return workDoneMsg{outputFile: path, finalSize: sizeStr, sizeBytes: sizeBytes, duration: duration, logPath: logPath, err: nil}
+}
+
+// runFFmpeg runs one FFmpeg command, reporting progress on ch. Its stderr is
+// appended to logPath and classified into an *ffmpegError on failure.
+func runFFmpeg(args []string, ch chan<- progressMsg, totalDuration float64, prefix string, logPath string) error {
+	_, err := runFFmpegOutput(args, ch, totalDuration, prefix, logPath)
+	return err
 }
 
-func runFFmpeg(args []string, ch chan<- progressMsg, totalDuration float64, prefix string) error {
+// runFFmpegOutput is runFFmpeg for analysis passes that need FFmpeg's stderr.
+// ch may be nil for the passes the wizard runs, which show no progress.
+func runFFmpegOutput(args []string, ch chan<- progressMsg, totalDuration float64, prefix string, logPath string) (string, error) {
 	finalArgs := append([]string{"-hide_banner", "-nostats", "-progress", "pipe:1"}, args...)
 	cmd := exec.Command("ffmpeg", finalArgs...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return err
+		return "", err
 	}
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
+	send := func(msg progressMsg) {
+		if ch != nil {
+			ch <- msg
+		}
+	}
+	send(progressMsg{kind: evCommand, debugCmd: "ffmpeg " + strings.Join(finalArgs, " "), args: finalArgs})
+	send(progressMsg{kind: evStageStart, stage: prefix, line: prefix})
+
 	if err := cmd.Start(); err != nil {
-		return err
+		appendJobLog(logPath, "ffmpeg", finalArgs, err.Error())
+		return "", newFFmpegError(prefix, err, "", logPath)
 	}
 
 	startTime := time.Now()
 
+	var cur, fps, speed float64
 	scanner := bufio.NewScanner(stdout)
 	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, "=")
-		if len(parts) == 2 && parts[0] == "out_time_us" {
-			us, _ := strconv.ParseFloat(parts[1], 64)
-			cur := us / 1000000.0
-
+		key, val, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		switch key {
+		case "out_time_us":
+			us, _ := strconv.ParseFloat(val, 64)
+			cur = us / 1000000.0
+		case "fps":
+			fps, _ = strconv.ParseFloat(val, 64)
+		case "speed":
+			speed, _ = strconv.ParseFloat(strings.TrimSuffix(val, "x"), 64)
+		case "progress":
 			pct := 0.0
 			if totalDuration > 0 {
 				pct = cur / totalDuration
 			}
-			if pct > 1.0 {
-				pct = 1.0
-			}
+			pct = math.Max(0, math.Min(pct, 1.0))
 
+			eta := -1.0
 			etaStr := "..."
 			if pct > 0.01 {
 				elapsed := time.Since(startTime).Seconds()
-				remaining := (elapsed / pct) - elapsed
-				if remaining < 0 {
-					remaining = 0
-				}
-				remDur := time.Duration(remaining) * time.Second
+				eta = math.Max(0, (elapsed/pct)-elapsed)
+				remDur := time.Duration(eta) * time.Second
 				etaStr = fmt.Sprintf("eta %02d:%02d", int(remDur.Minutes()), int(remDur.Seconds())%60)
 			}
 
-			ch <- progressMsg{
+			send(progressMsg{
+				kind:     evProgress,
 				line:     fmt.Sprintf("%s (%s)", prefix, etaStr),
 				progress: pct,
-			}
+				stage:    prefix,
+				fps:      fps,
+				speed:    speed,
+				eta:      eta,
+			})
 		}
 	}
 
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("%v\nLog: %s", err, stderr.String())
+	waitErr := cmd.Wait()
+	appendJobLog(logPath, "ffmpeg", finalArgs, stderr.String())
+	if waitErr != nil {
+		return "", newFFmpegError(prefix, waitErr, stderr.String(), logPath)
 	}
-	return nil
+	send(progressMsg{kind: evStageEnd, stage: prefix, elapsed: time.Since(startTime).Seconds()})
+	return stderr.String(), nil
 }
 
 func cleanPath(path string) string {
@@ -1173,19 +2693,69 @@ func findMatches(input string) []string {
 	return matches
 }
 
+type probeStream struct {
+	Index     int    `json:"index"`
+	CodecType string `json:"codec_type"`
+	CodecName string `json:"codec_name"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	BitRate   string `json:"bit_rate,omitempty"`
+	Channels  int    `json:"channels,omitempty"`
+
+	SampleRate   string `json:"sample_rate,omitempty"`
+	SideDataList []struct {
+		Rotation float64 `json:"rotation"`
+	} `json:"side_data_list,omitempty"`
+
+	AvgFrameRate string `json:"avg_frame_rate,omitempty"`
+	FieldOrder   string `json:"field_order,omitempty"`
+
+	Tags        map[string]string `json:"tags,omitempty"`
+	Disposition map[string]int    `json:"disposition,omitempty"`
+}
+
 type FFProbeOutput struct {
-	Streams []struct {
-		CodecType string `json:"codec_type"`
-	} `json:"streams"`
-	Format struct {
-		Duration string `json:"duration"`
+	Streams []probeStream `json:"streams"`
+	Format  struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+		BitRate    string `json:"bit_rate"`
 	} `json:"format"`
+	Chapters []probeChapter `json:"chapters"`
+}
+
+type probeChapter struct {
+	StartTime string            `json:"start_time"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// firstStream returns the first stream of the given codec type, or nil.
+func (info *FFProbeOutput) firstStream(codecType string) *probeStream {
+	for i := range info.Streams {
+		if info.Streams[i].CodecType == codecType {
+			return &info.Streams[i]
+		}
+	}
+	return nil
 }
 
-func probeFile(path string) (*FFProbeOutput, error) {
-	out, err := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", path).Output()
+// probeFile runs ffprobe on path. A failure is also written to logPath, if
+// set.
+func probeFile(path, logPath string) (*FFProbeOutput, error) {
+	args := []string{"-v", "error", "-print_format", "json", "-show_format", "-show_streams", "-show_chapters", path}
+	cmd := exec.Command("ffprobe", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
 	if err != nil {
-		return nil, err
+		logged := stderr.String()
+		if logged == "" {
+			logged = err.Error()
+		}
+		appendJobLog(logPath, "ffprobe", args, logged)
+		return nil, newFFmpegError("Probe", err, stderr.String(), logPath)
 	}
 	var info FFProbeOutput
 	json.Unmarshal(out, &info)
@@ -1200,9 +2770,59 @@ func printHelp() {
 	fmt.Println("  -gif                Encode to GIF")
 	fmt.Println("  -apng               Encode to animated PNG")
 	fmt.Println("  -avif               Encode to animated AVIF")
+	fmt.Println("  -audio              Compress the audio track only (opus, aac or mp3)")
 	fmt.Println("  -o [file]           Output file path")
+	fmt.Println("  -container [fmt]    Output container: mp4, mkv, webm, mov (default: from -o or codec)")
+	fmt.Println("  -acodec [codec]     Audio codec: aac, opus, mp3, vorbis, flac, ac3, alac, or auto to pick by container (default)")
+	fmt.Println("  -ab [kbit]          Audio bitrate, or auto to scale with the target (default auto)")
+	fmt.Println("  -ac [channels]      Audio channels: mono, stereo or a count")
+	fmt.Println("  -ar [hz]            Audio sample rate (e.g. 48000)")
+	fmt.Println("  -mute               Drop the audio track")
+	fmt.Println("  -streams [list]     Keep these input streams, by index (e.g. 0,2,3)")
+	fmt.Println("  -alang [langs]      Keep the audio tracks in these languages (e.g. eng,jpn)")
+	fmt.Println("  -slang [langs]      Keep the subtitle tracks in these languages, or all")
+	fmt.Println("  -burn [index|file]  Burn in a subtitle stream or .srt/.ass/.vtt file")
+	fmt.Println("  -crop [crop]        Crop: auto, W:H:X:Y, or an aspect like 9:16 with an anchor")
+	fmt.Println("  -speed [x]          Playback speed, e.g. 0.5, 2 or 8 for a timelapse")
+	fmt.Println("  -reframe [spec]     Reframe to an aspect: 9:16[:fill[:offset%]], 1:1:pad[:colour], 4:5:blur")
+	fmt.Println("  -vf [filters]       Extra FFmpeg video filters, run after the built-in ones")
+	fmt.Println("  -dedupe [mode]      Drop duplicate frames: auto (default), on, off")
+	fmt.Println("  -deinterlace [mode] Deinterlace: auto (default), on, off, double (one frame per field)")
+	fmt.Println("  -deinterlacer [f]   Deinterlacing filter: bwdif (default) or yadif")
+	fmt.Println("  -denoise [level]    Denoise before compressing: light, medium or strong")
+	fmt.Println("  -denoiser [f]       Denoising filter: hqdn3d (default, fast) or nlmeans (slower, sharper)")
+	fmt.Println("  -grain              With libsvtav1 or libaom-av1, remove film grain and resynthesise it on playback")
+	fmt.Println("  -stabilize [level]  Stabilize shaky video: light, medium or strong (vidstab, else deshake)")
+	fmt.Println("  -stab-zoom [pct]    Zoom in this much to hide stabilized edges (default auto)")
+	fmt.Println("  -dedupe-thresh [t]  Dedupe thresholds as hi:lo:frac (default 768:320:0.33)")
+	fmt.Println("  -fps-mode [mode]    Output frame rate: cfr or vfr (default: vfr when deduping)")
+	fmt.Println("  -cover              Keep cover art in audio mode (mp3, m4a, flac)")
+	fmt.Println("  -loudnorm [preset]  Normalize loudness: streaming (-14 LUFS), podcast (-16), broadcast (-23)")
+	fmt.Println("  -passthrough [p]    If the input already meets the target: ask, copy, skip, encode")
+	fmt.Println("  -name [template]    Output name template (default {name}_compressed)")
+	fmt.Println("                      Placeholders: {name} {size} {codec} {res} {date} {n} {part}")
+	fmt.Println("  -outdir [dir]       Output directory (default: next to the input)")
+	fmt.Println("  -exists [policy]    If the output exists: ask, increment, skip, overwrite")
 	fmt.Println("  -v                  Verbose mode (show command)")
-	fmt.Println("  -trim [start] [end] Trim video (e.g. -trim 00:01:00 00:02:00 or -trim 1s 5s)")
+	fmt.Println("  -join               Join all input files, in order, into one output")
+	fmt.Println("  -split-size [mb]    Split the output into parts of at most this size")
+	fmt.Println("  -split-time [time]  Split the output into parts of at most this length")
+	fmt.Println("  -split-at [point]   Where parts may start: keyframe (default) or scene")
+	fmt.Println("  -chapters           Export each chapter as its own file, named after its title")
+	fmt.Println("  -scenes [min]       Export each detected scene lasting at least min (e.g. 5s)")
+	fmt.Println("  -trim [start] [end] Trim video (e.g. -trim 00:01:00 00:02:00, -trim 1m30s -10 or -trim 300f 900f)")
+	fmt.Println("  -snap               Snap trim and cut points to keyframes; a single range is stream-copied")
+	fmt.Println("  -cut [ranges]       Keep only these ranges, joined (e.g. -cut 0:10-0:20,1:00-1:30)")
+	fmt.Println("  -cuts [file]        Read -cut ranges from a file, one or more per line")
+	fmt.Println("  -deadair [mode]     Trim black, frozen and silent stretches: ends, or all to cut the middle too")
+	fmt.Println("  -fade [secs]        Fade video and audio in and out at the start, end and each cut")
+	fmt.Println("  -size [mb]          Target size in MB (omit for CRF)")
+	fmt.Println("  -res [res]          Target resolution (e.g. 2 or 1280x720)")
+	fmt.Println("  -fps [fps]          Target framerate")
+	fmt.Println("  -codec [encoder]    FFmpeg encoder (e.g. libx264, hevc_nvenc)")
+	fmt.Println("  -crf [0-10]         Quality slider for CRF mode (default 5)")
+	fmt.Println("  -preset [0-4]       Encoding speed, 0=fastest (default 2)")
+	fmt.Println("  -json               Run without the TUI and print NDJSON events")
 	fmt.Println("  -h, --help, ?       Show this help message")
 }
 
@@ -1226,16 +2846,39 @@ func main() {
 			outputMode = modeAVIF
 			formatFlags++
 		}
+		if arg == "-audio" {
+			outputMode = modeAudio
+			formatFlags++
+		}
 	}
 
 	if formatFlags > 1 {
-		fmt.Println(errStyle.Render("Error: -gif, -apng, and -avif flags are mutually exclusive."))
+		fmt.Println(errStyle.Render("Error: -gif, -apng, -avif and -audio flags are mutually exclusive."))
 		os.Exit(1)
 	}
 
-	p := tea.NewProgram(initialModel(outputMode))
-	if _, err := p.Run(); err != nil {
+	m := initialModel(outputMode)
+	if m.err != nil && !m.jsonMode { // an invalid flag value
+		fmt.Println(errStyle.Render("Error: " + m.err.Error()))
+		os.Exit(1)
+	}
+	// Probing comes after the flag check, so a bad -cut spec is left for the
+	// trim step to fix rather than ending the program.
+	if m.filePath != "" && m.err == nil {
+		m = m.loadFile(m.filePath)
+	}
+	if m.jsonMode {
+		os.Exit(runJSON(m))
+	}
+
+	p := tea.NewProgram(m)
+	final, err := p.Run()
+	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	if fm, ok := final.(model); ok && fm.state == stateError {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errStyle.Render("Error:"), fm.err)
+		os.Exit(1)
+	}
 }
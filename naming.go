@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultNameTemplate = "{name}_compressed"
+
+type collisionPolicy string
+
+const (
+	collisionAsk       collisionPolicy = "ask"
+	collisionIncrement collisionPolicy = "increment"
+	collisionSkip      collisionPolicy = "skip"
+	collisionOverwrite collisionPolicy = "overwrite"
+)
+
+var collisionPolicies = []collisionPolicy{collisionAsk, collisionIncrement, collisionSkip, collisionOverwrite}
+
+// outputNaming decides where a job's output goes. custom is the -o path and
+// takes precedence over template and dir.
+type outputNaming struct {
+	template string
+	dir      string
+	custom   string
+	policy   collisionPolicy
+}
+
+// path renders the output path for opts. counter is the value of {n}; for
+// counter > 1 a template without {n} (or an -o path) gets a "_N" suffix.
+// Likewise the part of a split job is {part}, or a "_part" suffix.
+func (n outputNaming) path(opts encodeOptions, counter int) string {
+	ext := opts.outputExt()
+	if n.custom != "" {
+		custom := n.custom
+		if opts.part != "" {
+			customExt := filepath.Ext(custom)
+			custom = fmt.Sprintf("%s_%s%s", strings.TrimSuffix(custom, customExt), opts.part, customExt)
+		}
+		if counter <= 1 {
+			return custom
+		}
+		customExt := filepath.Ext(custom)
+		return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(custom, customExt), counter, customExt)
+	}
+
+	tmpl := n.template
+	if tmpl == "" {
+		tmpl = defaultNameTemplate
+	}
+	if opts.part != "" && !strings.Contains(tmpl, "{part}") {
+		tmpl += "_{part}"
+	}
+	if counter > 1 && !strings.Contains(tmpl, "{n}") {
+		tmpl += "_{n}"
+	}
+
+	size := "crf"
+	if opts.targetMB > 0 {
+		size = strconv.FormatFloat(opts.targetMB, 'f', -1, 64) + "MB"
+	}
+	codec := opts.codecCfg.FFmpegLib
+	if codec == "" {
+		codec = strings.ToLower(opts.codecCfg.Name)
+	}
+
+	name := strings.NewReplacer(
+		"{name}", strings.TrimSuffix(filepath.Base(opts.inputFile), filepath.Ext(opts.inputFile)),
+		"{size}", size,
+		"{codec}", codec,
+		"{res}", resolutionLabel(opts.resInput),
+		"{date}", time.Now().Format("2006-01-02"),
+		"{n}", strconv.Itoa(max(counter, 1)),
+		"{part}", opts.part,
+	).Replace(tmpl)
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	dir := n.dir
+	if dir == "" {
+		dir = filepath.Dir(opts.inputFile)
+	}
+	return filepath.Join(dir, name+ext)
+}
+
+// resolve returns the first candidate path. Under collisionIncrement it skips
+// ahead to a free name; otherwise exists reports whether the policy has to
+// decide what happens to an existing file.
+func (n outputNaming) resolve(opts encodeOptions) (path string, exists bool) {
+	path = n.path(opts, 1)
+	if !fileExists(path) {
+		return path, false
+	}
+	if n.policy == collisionIncrement {
+		return n.next(opts), false
+	}
+	return path, true
+}
+
+// next returns the first free path with a counter of 2 or more.
+func (n outputNaming) next(opts encodeOptions) string {
+	for i := 2; ; i++ {
+		if path := n.path(opts, i); !fileExists(path) {
+			return path
+		}
+	}
+}
+
+// resolutionLabel formats the resolution step's input for {res}.
+func resolutionLabel(input string) string {
+	input = strings.TrimSpace(input)
+	switch {
+	case input == "" || input == "1":
+		return "orig"
+	case input == "2":
+		return "half"
+	case strings.ContainsAny(input, "x:"):
+		return strings.ReplaceAll(input, ":", "x")
+	}
+	return "1-" + input
+}
+
+// partPath names a part of a split job after first, the path chosen for the
+// job's first part, by swapping that part's label firstLabel for part.
+func partPath(first, firstLabel, part string) string {
+	dir, base := filepath.Split(first)
+	if i := strings.LastIndex(base, firstLabel); firstLabel != "" && i >= 0 {
+		return dir + base[:i] + part + base[i+len(firstLabel):]
+	}
+	ext := filepath.Ext(base)
+	return fmt.Sprintf("%s%s_%s%s", dir, strings.TrimSuffix(base, ext), part, ext)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// tempOutputPath is where a job encodes before its output is committed. It
+// sits next to the final file so the rename stays on one filesystem, and keeps
+// the extension so FFmpeg still picks the right muxer.
+func tempOutputPath(path string) string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	return filepath.Join(dir, fmt.Sprintf(".%s.%d.part%s", strings.TrimSuffix(base, ext), time.Now().UnixNano(), ext))
+}
+
+// commitOutput moves a finished temp file into place. If something created
+// path while we were encoding and overwriting wasn't allowed, the output is
+// saved under the next free "_N" name instead.
+func commitOutput(tmpPath, path string, overwrite bool) (string, error) {
+	if !overwrite && fileExists(path) {
+		naming := outputNaming{custom: path}
+		path = naming.next(encodeOptions{})
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("could not save output: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPartPath(t *testing.T) {
+	tests := []struct {
+		first, firstLabel, part string
+		want                    string
+	}{
+		{"out/in_compressed_part01.mp4", "part01", "part02", "out/in_compressed_part02.mp4"},
+		{"out/in_compressed_part01_2.mp4", "part01", "part03", "out/in_compressed_part03_2.mp4"},
+		{"out/part01_renamed.mkv", "part01", "part02", "out/part02_renamed.mkv"},
+		{"out/01_Intro.mp4", "01_Intro", "02_Outro", "out/02_Outro.mp4"},
+		{"out/clip.mp4", "scene01", "scene02", "out/clip_scene02.mp4"},
+	}
+	for _, tt := range tests {
+		got := partPath(filepath.FromSlash(tt.first), tt.firstLabel, tt.part)
+		if want := filepath.FromSlash(tt.want); got != want {
+			t.Errorf("partPath(%q, %q, %q) = %q, want %q", tt.first, tt.firstLabel, tt.part, got, want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type passthroughPolicy string
+
+const (
+	passAsk    passthroughPolicy = "ask"
+	passCopy   passthroughPolicy = "copy"
+	passSkip   passthroughPolicy = "skip"
+	passEncode passthroughPolicy = "encode"
+)
+
+var passthroughPolicies = []passthroughPolicy{passAsk, passCopy, passSkip, passEncode}
+
+// needsEncode reports whether the job changes the picture or sound in a way
+// only an encode can, whatever the input already is.
+func (o encodeOptions) needsEncode() bool {
+	return o.mode != modeVideo ||
+		o.audio.mute ||
+		o.audio.codec != "" ||
+		o.audio.kbit > 0 ||
+		o.audio.channels > 0 ||
+		o.audio.sampleRate > 0 ||
+		o.loudnorm != "" ||
+		o.burn != nil ||
+		o.extraFilters != "" ||
+		o.crop != "" ||
+		o.reframe != nil ||
+		o.speedFactor() != 1 ||
+		o.dedupe.mode == dedupeOn ||
+		o.denoise.preset != "" ||
+		o.stabilize != nil ||
+		o.deinterlace.mode == deinterlaceOn ||
+		o.deinterlace.mode == deinterlaceDouble ||
+		len(o.cuts) > 1 ||
+		o.deadAir != "" ||
+		o.fade > 0 ||
+		len(o.joinFiles) > 1
+}
+
+// passthroughReason explains why re-encoding the input wouldn't help, or
+// returns "" if it should be encoded. In size mode that's an input already
+// under the target; in CRF mode, one already in the target codec with no
+// resize or framerate change requested. Jobs for which needsEncode holds
+// are always encoded.
+func passthroughReason(opts encodeOptions, info *FFProbeOutput, duration float64) string {
+	if opts.needsEncode() || opts.streams == nil {
+		return ""
+	}
+	v := info.stream(opts.streams.video)
+	if v == nil {
+		return ""
+	}
+
+	if opts.targetMB > 0 {
+		size := sourceBytes(opts, info, duration)
+		if size > 0 && size <= opts.targetMB*1024*1024 {
+			return fmt.Sprintf("it is already %.2f MB, under the %g MB target", size/1024/1024, opts.targetMB)
+		}
+		return ""
+	}
+
+	if videoFamily(opts.codecCfg.FFmpegLib) == v.CodecName && buildScaleFilter(opts.resInput) == nil && opts.fpsInput == "" {
+		return fmt.Sprintf("it is already %s at the requested resolution", strings.ToUpper(v.CodecName))
+	}
+	return ""
+}
+
+// sourceBytes is the size of the part of the input the job covers, estimated
+// from the overall bitrate when trimming.
+func sourceBytes(opts encodeOptions, info *FFProbeOutput, duration float64) float64 {
+	if len(opts.cuts) > 0 {
+		bitRate, _ := strconv.ParseFloat(info.Format.BitRate, 64)
+		return bitRate * duration / 8
+	}
+	size, _ := strconv.ParseFloat(info.Format.Size, 64)
+	return size
+}
+
+// copyableAudio returns the bitrate of audio stream a if it can be copied
+// into ctr as-is within budget bits/s, or 0 if it has to be re-encoded.
+func copyableAudio(a *probeStream, ctr containerInfo, budget float64) float64 {
+	if a == nil || !ctr.acceptsAudio(a.CodecName) {
+		return 0
+	}
+	bitRate, _ := strconv.ParseFloat(a.BitRate, 64)
+	if bitRate <= 0 || bitRate > budget {
+		return 0
+	}
+	return bitRate
+}
+
+// remuxArgs builds a stream copy of the selected streams into ctr. Audio
+// the container can't hold is re-encoded; video it can't hold is an error.
+func remuxArgs(inputFile string, info *FFProbeOutput, sel streamSelection, ctr containerInfo, trimArgs, formatArgs []string, outputFile string) ([]string, error) {
+	v := info.stream(sel.video)
+	if v == nil {
+		return nil, fmt.Errorf("no video stream to copy")
+	}
+	if ctr.Video != nil && !slices.Contains(ctr.Video, v.CodecName) {
+		return nil, fmt.Errorf("%s video can't be copied into %s", strings.ToUpper(v.CodecName), strings.ToUpper(ctr.Name))
+	}
+
+	args := []string{"-y"}
+	args = append(args, trimArgs...)
+	args = append(args, "-i", inputFile)
+	args = append(args, sel.mapArgs(true)...)
+	args = append(args, "-c:v", "copy")
+	if len(sel.audio) > 0 {
+		copyAll := true
+		for _, idx := range sel.audio {
+			if a := info.stream(idx); a == nil || !ctr.acceptsAudio(a.CodecName) {
+				copyAll = false
+			}
+		}
+		if copyAll {
+			args = append(args, "-c:a", "copy")
+		} else {
+			args = append(args, "-c:a", audioEncoders[ctr.defaultAudioCodec()], "-b:a", "128k")
+		}
+	}
+	subArgs, err := subtitleArgs(info, sel, ctr)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, subArgs...)
+	args = append(args, formatArgs...)
+	args = append(args, outputFile)
+	return args, nil
+}
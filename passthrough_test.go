@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestPassthroughReasonAudioSettings(t *testing.T) {
+	info := &FFProbeOutput{Streams: []probeStream{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "aac", Channels: 2},
+	}}
+	info.Format.Size = "1048576"
+	base := encodeOptions{mode: modeVideo, targetMB: 10, streams: &streamSelection{video: 0, audio: []int{1}}}
+
+	tests := []struct {
+		name  string
+		audio audioSettings
+		want  bool // passthrough offered
+	}{
+		{"unchanged", audioSettings{}, true},
+		{"mute", audioSettings{mute: true}, false},
+		{"codec", audioSettings{codec: "opus"}, false},
+		{"bitrate", audioSettings{kbit: 96}, false},
+		{"channels", audioSettings{channels: 1}, false},
+		{"sample rate", audioSettings{sampleRate: 44100}, false},
+	}
+	for _, tt := range tests {
+		opts := base
+		opts.audio = tt.audio
+		if got := passthroughReason(opts, info, 60) != ""; got != tt.want {
+			t.Errorf("%s: passthrough offered = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
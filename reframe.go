@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// reframeModes are the ways a video is fitted to a new aspect ratio: crop it
+// to fill the frame, pad it with solid bars, or pad it with a blurred,
+// enlarged copy of itself.
+var reframeModes = []string{"fill", "pad", "blur"}
+
+// reframeSettings reframe the video to aspectW:aspectH, e.g. 9:16 for
+// vertical feeds.
+type reframeSettings struct {
+	aspectW, aspectH int
+	mode             string
+	offset           float64 // fill: horizontal position of the crop, 0 = left, 1 = right
+	color            string  // pad: bar colour
+}
+
+// parseReframe reads -reframe: "9:16", "9:16:fill:30" (offset in percent),
+// "1:1:pad:white" or "4:5 blur".
+func parseReframe(spec string) (*reframeSettings, error) {
+	fields := strings.FieldsFunc(strings.ToLower(spec), func(r rune) bool { return r == ':' || r == ' ' })
+	invalid := fmt.Errorf("invalid reframe %q: use e.g. 9:16, 9:16:fill:30, 1:1:pad:white or 4:5:blur", spec)
+	if len(fields) < 2 || len(fields) > 4 {
+		return nil, invalid
+	}
+
+	r := &reframeSettings{mode: "fill", offset: 0.5, color: "black"}
+	var err1, err2 error
+	r.aspectW, err1 = strconv.Atoi(fields[0])
+	r.aspectH, err2 = strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil || r.aspectW <= 0 || r.aspectH <= 0 {
+		return nil, invalid
+	}
+	if len(fields) > 2 {
+		if !slices.Contains(reframeModes, fields[2]) {
+			return nil, invalid
+		}
+		r.mode = fields[2]
+	}
+	if len(fields) > 3 {
+		switch r.mode {
+		case "fill":
+			pct, err := strconv.ParseFloat(strings.TrimSuffix(fields[3], "%"), 64)
+			if err != nil || pct < 0 || pct > 100 {
+				return nil, fmt.Errorf("invalid reframe offset %q: use 0 (left) to 100 (right)", fields[3])
+			}
+			r.offset = pct / 100
+		case "pad":
+			r.color = fields[3]
+		default:
+			return nil, invalid
+		}
+	}
+	return r, nil
+}
+
+// maxAspectTerm is the largest term of a bare aspect such as "9:16"; a
+// resolution step answer with larger numbers is a size in pixels.
+const maxAspectTerm = 32
+
+// isReframe reports whether a resolution step answer is a reframe spec
+// rather than a size: it names a mode such as "9:16 blur", or is a bare
+// aspect such as "9:16", which fills like -reframe does.
+func isReframe(input string) bool {
+	for _, mode := range reframeModes {
+		if strings.Contains(strings.ToLower(input), mode) {
+			return true
+		}
+	}
+	w, h, ok := strings.Cut(strings.TrimSpace(input), ":")
+	if !ok {
+		return false
+	}
+	aw, err1 := strconv.Atoi(w)
+	ah, err2 := strconv.Atoi(h)
+	return err1 == nil && err2 == nil && aw > 0 && ah > 0 && aw <= maxAspectTerm && ah <= maxAspectTerm
+}
+
+func (r *reframeSettings) label() string {
+	return fmt.Sprintf("%d:%d %s", r.aspectW, r.aspectH, r.mode)
+}
+
+func (r *reframeSettings) aspect() float64 {
+	return float64(r.aspectW) / float64(r.aspectH)
+}
+
+// baseSize is the output size before the resolution step: the largest crop
+// of a w x h source for fill, and for the padded modes a frame whose short
+// side matches the source's, so 1920x1080 becomes 1080x1920 for 9:16.
+func (r *reframeSettings) baseSize(w, h int) (int, int) {
+	if r.mode == "fill" {
+		c := aspectCrop(r.aspect(), "center", w, h)
+		return c.W, c.H
+	}
+	short := float64(min(w, h))
+	if r.aspect() <= 1 {
+		return int(short), int(short / r.aspect())
+	}
+	return int(short * r.aspect()), int(short)
+}
+
+// size is the exact, even output size. The resolution step scales the base
+// size by a divisor ("2"), or sets it outright ("1080x1920"); a negative side
+// ("720x-2") follows the aspect ratio.
+func (r *reframeSettings) size(res string, w, h int) (int, int, error) {
+	outW, outH := r.baseSize(w, h)
+	res = strings.TrimSpace(res)
+	if div, err := strconv.ParseFloat(res, 64); err == nil && div > 0 {
+		outW, outH = int(float64(outW)/div), int(float64(outH)/div)
+	} else if ws, hs, ok := strings.Cut(strings.ReplaceAll(res, "x", ":"), ":"); ok {
+		rw, err1 := strconv.Atoi(ws)
+		rh, err2 := strconv.Atoi(hs)
+		switch {
+		case err1 != nil || err2 != nil || (rw <= 0 && rh <= 0):
+			return 0, 0, fmt.Errorf("invalid resolution %q", res)
+		case rw <= 0:
+			outW, outH = int(float64(rh)*r.aspect()), rh
+		case rh <= 0:
+			outW, outH = rw, int(float64(rw)/r.aspect())
+		default:
+			outW, outH = rw, rh
+		}
+	}
+	outW, outH = outW&^1, outH&^1
+	if outW < 2 || outH < 2 {
+		return 0, 0, fmt.Errorf("reframed size %dx%d is too small", outW, outH)
+	}
+	return outW, outH, nil
+}
+
+// filters reframes a w x h picture, taking the place of the scale filter.
+func (r *reframeSettings) filters(res string, w, h int) ([]videoFilter, error) {
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("the source size is unknown, can't reframe")
+	}
+	outW, outH, err := r.size(res, w, h)
+	if err != nil {
+		return nil, err
+	}
+	ow, oh := strconv.Itoa(outW), strconv.Itoa(outH)
+
+	cover := newFilter(stageScale, "scale", kv("w", ow), kv("h", oh), kv("force_original_aspect_ratio", "increase"))
+	fit := newFilter(stageScale, "scale", kv("w", ow), kv("h", oh), kv("force_original_aspect_ratio", "decrease"), kv("force_divisible_by", "2"))
+	setsar := newFilter(stageScale, "setsar", pos("1"))
+
+	switch r.mode {
+	case "pad":
+		pad := newFilter(stageScale, "pad", kv("w", ow), kv("h", oh), kv("x", "(ow-iw)/2"), kv("y", "(oh-ih)/2"), kv("color", r.color))
+		return []videoFilter{fit, pad, setsar}, nil
+	case "blur":
+		// one input and one output, so it still works as part of -vf
+		bg := []videoFilter{cover, newFilter(stageScale, "crop", kv("w", ow), kv("h", oh)), newFilter(stageScale, "boxblur", kv("luma_radius", "min(w,h)/20"), kv("luma_power", "2"))}
+		graph := fmt.Sprintf("split[rf_a][rf_b];[rf_a]%s[rf_bg];[rf_b]%s[rf_fg];[rf_bg][rf_fg]%s,%s",
+			filterPipeline{bg}.chain(), fit, newFilter(stageScale, "overlay", kv("x", "(W-w)/2"), kv("y", "(H-h)/2")), setsar)
+		return []videoFilter{{stage: stageScale, raw: graph}}, nil
+	}
+	crop := newFilter(stageScale, "crop", kv("w", ow), kv("h", oh), kv("x", fmt.Sprintf("(iw-ow)*%g", r.offset)), kv("y", "(ih-oh)/2"))
+	return []videoFilter{cover, crop, setsar}, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// speedAudioMax is the fastest speed that keeps the audio. Beyond it a
+// timelapse's sound is just noise, so it is dropped.
+const speedAudioMax = 4.0
+
+// parseSpeed reads -speed: "2", "2x" or "0.5x".
+func parseSpeed(s string) (float64, error) {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "x"), 64)
+	if err != nil || speed < 0.1 || speed > 100 {
+		return 0, fmt.Errorf("invalid speed %q: use 0.1 to 100, e.g. 0.5, 2 or 8", s)
+	}
+	return speed, nil
+}
+
+// speedFilters change the video's timestamps. Speeding up without an -fps
+// would multiply the frame rate, so the source rate is kept by dropping
+// frames instead.
+func speedFilters(speed float64, v *probeStream, fpsInput string) []videoFilter {
+	filters := []videoFilter{newFilter(stageFPS, "setpts", pos(fmt.Sprintf("PTS/%g", speed)))}
+	if speed > 1 && fpsInput == "" && v != nil && v.frameRate() > 0 {
+		filters = append(filters, newFilter(stageFPS, "fps", pos(v.AvgFrameRate)))
+	}
+	return filters
+}
+
+// atempoChain changes the audio tempo by speed. A single atempo is only
+// guaranteed to handle 0.5 to 2, so larger changes are chained.
+func atempoChain(speed float64) string {
+	var parts []string
+	for ; speed > 2; speed /= 2 {
+		parts = append(parts, "atempo=2")
+	}
+	for ; speed < 0.5; speed /= 0.5 {
+		parts = append(parts, "atempo=0.5")
+	}
+	if speed != 1 {
+		parts = append(parts, "atempo="+strconv.FormatFloat(speed, 'g', 6, 64))
+	}
+	return strings.Join(parts, ",")
+}
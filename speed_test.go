@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestAtempoChain(t *testing.T) {
+	tests := []struct {
+		speed float64
+		want  string
+	}{
+		{1, ""},
+		{1.5, "atempo=1.5"},
+		{2, "atempo=2"},
+		{3, "atempo=2,atempo=1.5"},
+		{8, "atempo=2,atempo=2,atempo=2"},
+		{0.5, "atempo=0.5"},
+		{0.75, "atempo=0.75"},
+		{0.25, "atempo=0.5,atempo=0.5"},
+		{0.1, "atempo=0.5,atempo=0.5,atempo=0.5,atempo=0.8"},
+	}
+	for _, tt := range tests {
+		if got := atempoChain(tt.speed); got != tt.want {
+			t.Errorf("atempoChain(%g) = %q, want %q", tt.speed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// splitSettings cut a job's output into several parts: each chapter, each
+// scene of at least minScene seconds, or else parts of at most sizeMB and at
+// most seconds long. Zero means no limit of that kind.
+type splitSettings struct {
+	by       string // "", "chapters" or "scenes"
+	minScene float64
+	sizeMB   float64
+	seconds  float64
+	at       string // splitPoints entry: where part boundaries may fall
+}
+
+// splitPoints are the places a part boundary is moved to when one is near.
+var splitPoints = []string{"keyframe", "scene"}
+
+// splitMinBPP is the lowest video bitrate, in bits per pixel per frame, that a
+// part of a size-limited split without -size is planned to get. Parts are
+// made short enough to reach it rather than squeezing a long recording into
+// one unwatchable part.
+const splitMinBPP = 0.04
+
+// sceneThreshold is the scene score above which a frame starts a new scene.
+const sceneThreshold = 0.3
+
+func (s *splitSettings) label() string {
+	switch s.by {
+	case "chapters":
+		return "each chapter"
+	case "scenes":
+		return "scenes over " + formatDuration(s.minScene)
+	}
+	var limits []string
+	if s.sizeMB > 0 {
+		limits = append(limits, strconv.FormatFloat(s.sizeMB, 'f', -1, 64)+" MB")
+	}
+	if s.seconds > 0 {
+		limits = append(limits, formatDuration(s.seconds))
+	}
+	return strings.Join(limits, ", ") + " parts at " + s.at + "s"
+}
+
+// partLabel names part i (from 1) for {part} in output names.
+func partLabel(i int) string {
+	return fmt.Sprintf("part%02d", i)
+}
+
+// maxPartLength is the longest a part of a job lasting total seconds may be.
+// With a total -size each part gets its share of it, so the share must stay
+// under the part limit; without one, parts are encoded at the part limit and
+// kept short enough for splitMinBPP at the output frame size.
+func (s *splitSettings) maxPartLength(opts encodeOptions, info *FFProbeOutput, total float64) float64 {
+	maxLen := math.Inf(1)
+	if s.seconds > 0 {
+		maxLen = s.seconds
+	}
+	if s.sizeMB <= 0 {
+		return maxLen
+	}
+	if opts.targetMB > 0 {
+		return min(maxLen, total*s.sizeMB/opts.targetMB)
+	}
+
+	rate := 128 * 1000.0 // audio
+	if opts.mode != modeAudio && opts.streams != nil {
+		if v := info.stream(opts.streams.video); v != nil {
+			w, h := outputFrameSize(opts.resInput, v.Width, v.Height)
+			fps := v.frameRate()
+			if f, err := strconv.ParseFloat(opts.fpsInput, 64); err == nil && f > 0 {
+				fps = f
+			}
+			rate += splitMinBPP * float64(w*h) * max(fps, 1)
+		}
+	}
+	return min(maxLen, s.sizeMB*8388608/rate)
+}
+
+// partBudget is the size target of a part lasting length seconds out of
+// total, or 0 for CRF mode. Chapters and scenes are files of their own and
+// each get the whole target.
+func (s *splitSettings) partBudget(targetMB, length, total float64) float64 {
+	if s.by != "" {
+		return targetMB
+	}
+	if targetMB <= 0 {
+		return s.sizeMB
+	}
+	share := math.Floor(targetMB*length/total*100) / 100
+	if s.sizeMB > 0 {
+		return min(s.sizeMB, share)
+	}
+	return share
+}
+
+// outputFrameSize estimates the frame size the resolution step gives a w x h
+// source: a divisor or an explicit size, one side of which may be negative.
+func outputFrameSize(res string, w, h int) (int, int) {
+	res = strings.TrimSpace(res)
+	if div, err := strconv.ParseFloat(res, 64); err == nil && div > 0 {
+		return int(float64(w) / div), int(float64(h) / div)
+	}
+	ws, hs, ok := strings.Cut(strings.ReplaceAll(res, "x", ":"), ":")
+	if !ok {
+		return w, h
+	}
+	rw, _ := strconv.Atoi(ws)
+	rh, _ := strconv.Atoi(hs)
+	switch {
+	case rw > 0 && rh > 0:
+		return rw, rh
+	case rw > 0 && w > 0:
+		return rw, rw * h / w
+	case rh > 0 && h > 0:
+		return rh * w / h, rh
+	}
+	return w, h
+}
+
+// planSplit divides [start, end) into parts of at most maxLen seconds, as
+// even as the boundaries allow. Each boundary moves to the nearest of points
+// that keeps the part between half its even share and maxLen long.
+func planSplit(start, end, maxLen float64, points []float64) []keepRange {
+	var parts []keepRange
+	prev := start
+	for end-prev > maxLen+0.001 {
+		remaining := end - prev
+		share := remaining / math.Ceil(remaining/maxLen)
+		ideal := prev + share
+		cut := ideal
+		best := math.Inf(1)
+		for _, p := range points {
+			if p > prev+share/2 && p <= prev+maxLen && math.Abs(p-ideal) < best {
+				cut, best = p, math.Abs(p-ideal)
+			}
+		}
+		parts = append(parts, keepRange{prev, cut})
+		prev = cut
+	}
+	return append(parts, keepRange{prev, end})
+}
+
+var showinfoTimeRe = regexp.MustCompile(`pts_time:\s*([0-9.]+)`)
+
+// detectScenes returns the source times where a new scene starts in
+// [start, start+duration), decoding a small copy of the picture for speed.
+func detectScenes(file string, video int, start, duration float64, ch chan<- progressMsg, logPath string) ([]float64, error) {
+	p := filterPipeline{}
+	p.add(newFilter(stageScale, "scale", kv("w", "320"), kv("h", "-2")),
+		newFilter(stageExtra, "select", pos(fmt.Sprintf("gt(scene,%g)", sceneThreshold))),
+		newFilter(stageExtra, "showinfo"))
+	args := []string{"-y", "-ss", formatSeconds(start), "-t", formatSeconds(duration), "-i", file,
+		"-map", fmt.Sprintf("0:%d", video), "-an", "-sn", "-vf", p.chain(), "-f", "null", "-"}
+	stderr, err := runFFmpegOutput(args, ch, duration, "Scene Detection", logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var scenes []float64
+	for _, m := range showinfoTimeRe.FindAllStringSubmatch(stderr, -1) {
+		if t, err := strconv.ParseFloat(m[1], 64); err == nil {
+			scenes = append(scenes, start+t)
+		}
+	}
+	slices.Sort(scenes)
+	return scenes, nil
+}
+
+// plan returns the source ranges of the parts of [start, start+length) and
+// their {part} labels.
+func (s *splitSettings) plan(opts encodeOptions, info *FFProbeOutput, start, length float64, ch chan<- progressMsg, logPath string) ([]keepRange, []string, error) {
+	var parts []keepRange
+	v := info.stream(opts.streams.video)
+	if opts.mode == modeAudio {
+		v = nil
+	}
+
+	switch s.by {
+	case "chapters":
+		var labels []string
+		for i, c := range info.Chapters {
+			from, _ := strconv.ParseFloat(c.StartTime, 64)
+			to, _ := strconv.ParseFloat(c.EndTime, 64)
+			r := keepRange{max(from, start), min(to, start+length)}
+			if r.length() <= 0 {
+				continue // outside the trim
+			}
+			parts = append(parts, r)
+			labels = append(labels, chapterLabel(i+1, c.Tags["title"]))
+		}
+		if len(parts) == 0 {
+			return nil, nil, fmt.Errorf("the input has no chapters")
+		}
+		return parts, labels, nil
+
+	case "scenes":
+		if v == nil {
+			return nil, nil, fmt.Errorf("scene detection needs a video stream")
+		}
+		scenes, err := detectScenes(opts.inputFile, v.Index, start, length, ch, logPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		bounds := append(append([]float64{start}, scenes...), start+length)
+		var labels []string
+		skipped := 0
+		for i := 1; i < len(bounds); i++ {
+			r := keepRange{bounds[i-1], bounds[i]}
+			if r.length() < s.minScene {
+				skipped++
+				continue
+			}
+			parts = append(parts, r)
+			labels = append(labels, fmt.Sprintf("scene%02d", len(parts)))
+		}
+		if skipped > 0 {
+			ch <- progressMsg{line: fmt.Sprintf("Leaving out %d scenes shorter than %s", skipped, formatDuration(s.minScene))}
+		}
+		if len(parts) == 0 {
+			return nil, nil, fmt.Errorf("no scene lasts %s or more", formatDuration(s.minScene))
+		}
+		return parts, labels, nil
+	}
+
+	var points []float64
+	if v != nil {
+		var err error
+		if s.at == "scene" {
+			points, err = detectScenes(opts.inputFile, v.Index, start, length, ch, logPath)
+		} else {
+			total, _ := strconv.ParseFloat(info.Format.Duration, 64)
+			points, err = probeKeyframes(opts.inputFile, v.Index, total, ch, logPath)
+		}
+		if err != nil {
+			ch <- progressMsg{kind: evWarning, line: fmt.Sprintf("could not find %ss (%v), splitting at exact times", s.at, err)}
+		}
+	}
+	// the split is planned on the source timeline; limits apply to the output
+	speed := opts.speedFactor()
+	maxLen := s.maxPartLength(opts, info, length/speed) * speed
+	parts = planSplit(start, start+length, maxLen, points)
+	labels := make([]string, len(parts))
+	for i := range parts {
+		labels[i] = partLabel(i + 1)
+	}
+	return parts, labels, nil
+}
+
+// chapterLabel names chapter i (from 1) for {part}: its number and title,
+// made safe for file names.
+func chapterLabel(i int, title string) string {
+	title = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	title = strings.Trim(title, " .")
+	if title == "" {
+		return fmt.Sprintf("%02d", i)
+	}
+	return fmt.Sprintf("%02d_%s", i, title)
+}
+
+// runSplit plans the parts of a split job and encodes each of them as a job
+// of its own, so every part has its own size budget and plays on its own.
+func runSplit(opts encodeOptions, ch chan progressMsg) workDoneMsg {
+	split := opts.split
+	logPath := newJobLog(opts.inputFile)
+	info, err := probeFile(opts.inputFile, logPath)
+	if err != nil {
+		return workDoneMsg{err: err}
+	}
+	if opts.streams == nil {
+		sel := defaultStreams(info, nil, nil, nil)
+		opts.streams = &sel
+	}
+	opts.trimDeadAir(info, ch, logPath)
+	if len(opts.cuts) > 1 {
+		return workDoneMsg{err: fmt.Errorf("a split can't be combined with several cut ranges")}
+	}
+	start, length := opts.sourceWindow(info)
+	if length <= 0 {
+		return workDoneMsg{err: fmt.Errorf("the duration is unknown, can't split")}
+	}
+
+	parts, labels, err := split.plan(opts, info, start, length, ch, logPath)
+	if err != nil {
+		return workDoneMsg{logPath: logPath, err: err}
+	}
+	ch <- progressMsg{line: fmt.Sprintf("Splitting into %d parts: %s", len(parts), formatRanges(parts))}
+
+	// the first part's path was settled with the user, or by the policy, and
+	// names the rest; an overwrite covers every part
+	overwrite := opts.overwrite || opts.naming.policy == collisionOverwrite
+	done := workDoneMsg{logPath: logPath}
+	for i, r := range parts {
+		p := opts
+		p.split = nil
+		p.cuts = []keepRange{r}
+		p.part = labels[i]
+		p.targetMB = split.partBudget(opts.targetMB, r.length(), length)
+		p.outputFile = partPath(opts.outputFile, opts.part, labels[i])
+		p.overwrite = overwrite
+		if !overwrite && fileExists(p.outputFile) {
+			if p.naming.policy == collisionSkip {
+				ch <- progressMsg{kind: evWarning, line: fmt.Sprintf("%s already exists, skipping part %d", p.outputFile, i+1)}
+				continue
+			}
+			ch <- progressMsg{kind: evWarning, line: fmt.Sprintf("%s already exists, saving part %d under the next free name", p.outputFile, i+1)}
+		}
+		if p.passthrough == passAsk {
+			p.passthrough = passEncode // nobody to ask halfway through
+		}
+
+		ch <- progressMsg{line: fmt.Sprintf("Part %d of %d: %s", i+1, len(parts), r)}
+		res := encodeJob(p, ch)
+		if res.err != nil {
+			res.err = fmt.Errorf("part %d: %w", i+1, res.err)
+			res.parts = done.parts
+			return res
+		}
+		done.parts = append(done.parts, res)
+		done.sizeBytes += res.sizeBytes
+		done.duration += res.duration
+	}
+	if len(done.parts) == 0 {
+		done.skipReason = "every part already exists"
+		return done
+	}
+	done.outputFile = done.parts[0].outputFile
+	done.finalSize = fmt.Sprintf("%d parts, %.2f MB in total", len(done.parts), float64(done.sizeBytes)/1024/1024)
+	return done
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// stabilizePresets are the -stabilize strengths, lightest first.
+var stabilizePresets = []string{"light", "medium", "strong"}
+
+// stabilizeLevels hold each preset's settings: how shaky vidstabdetect
+// takes the video to be, how many frames either side vidstabtransform
+// smooths the camera path over, and how far deshake searches for motion.
+var stabilizeLevels = map[string]struct {
+	shakiness, smoothing, search int
+}{
+	"light":  {4, 10, 16},
+	"medium": {6, 20, 32},
+	"strong": {8, 40, 64},
+}
+
+// stabilizeSettings control stabilization. zoom is the percentage to zoom
+// in by to hide the moving edges, or -1 to let vidstab pick the least that
+// does. transforms is the vidstabdetect result once the analysis has run;
+// without one deshake is used.
+type stabilizeSettings struct {
+	strength   string
+	zoom       float64
+	transforms string
+}
+
+func (s stabilizeSettings) label() string {
+	if s.zoom < 0 {
+		return s.strength
+	}
+	return fmt.Sprintf("%s, %g%% zoom", s.strength, s.zoom)
+}
+
+// hasVidstab reports whether the FFmpeg build has both vidstab filters.
+func hasVidstab() bool {
+	return hasFilter("vidstabdetect") && hasFilter("vidstabtransform")
+}
+
+// filters stabilize a w x h picture: with vidstab's transforms when the
+// analysis produced them, else with deshake, zoomed by cropping the middle
+// and scaling it back.
+func (s stabilizeSettings) filters(w, h int) []videoFilter {
+	level := stabilizeLevels[s.strength]
+	if s.transforms != "" {
+		transform := newFilter(stageStabilize, "vidstabtransform", kv("input", filterPath(s.transforms)),
+			kv("smoothing", strconv.Itoa(level.smoothing)), kv("interpol", "bicubic"))
+		if s.zoom < 0 {
+			transform.args = append(transform.args, kv("optzoom", "1"))
+		} else {
+			transform.args = append(transform.args, kv("optzoom", "0"), kv("zoom", strconv.FormatFloat(s.zoom, 'g', -1, 64)))
+		}
+		// vidstab's interpolation softens the picture a little
+		return []videoFilter{transform, newFilter(stageStabilize, "unsharp", pos("5"), pos("5"), pos("0.8"), pos("3"), pos("3"), pos("0.4"))}
+	}
+
+	search := strconv.Itoa(level.search)
+	filters := []videoFilter{newFilter(stageStabilize, "deshake", kv("rx", search), kv("ry", search))}
+	if s.zoom > 0 && w > 0 && h > 0 {
+		z := strconv.FormatFloat(1+s.zoom/100, 'g', -1, 64)
+		filters = append(filters,
+			newFilter(stageStabilize, "crop", kv("w", "iw/"+z), kv("h", "ih/"+z)),
+			newFilter(stageStabilize, "scale", kv("w", strconv.Itoa(w)), kv("h", strconv.Itoa(h))))
+	}
+	return filters
+}
+
+// analyzeShake runs vidstabdetect over the job's video as its own stage,
+// through the filters that come before stabilization so it sees the frames
+// the encode will. It returns the transform file, which the caller removes.
+func analyzeShake(opts encodeOptions, info *FFProbeOutput, sel streamSelection, duration float64, ch chan<- progressMsg, logPath string) (string, error) {
+	before := opts
+	before.stabilize = nil
+	p, err := videoPipeline(before, info)
+	if err != nil {
+		return "", err
+	}
+	p = p.before(stageStabilize)
+
+	trf := filepath.Join(os.TempDir(), fmt.Sprintf("stab_%d.trf", time.Now().UnixNano()))
+	level := stabilizeLevels[opts.stabilize.strength]
+	p.add(newFilter(stageStabilize, "vidstabdetect", kv("shakiness", strconv.Itoa(level.shakiness)),
+		kv("accuracy", "15"), kv("result", filterPath(trf))))
+
+	args := []string{"-y"}
+	args = append(args, opts.trimArgs()...)
+	args = append(args, "-i", opts.inputFile, "-map", fmt.Sprintf("0:%d", sel.video), "-an", "-sn")
+	args = append(args, p.vfArgs()...)
+	args = append(args, "-f", "null", "-")
+	if err := runFFmpeg(args, ch, duration, "Stabilization Analysis", logPath); err != nil {
+		os.Remove(trf)
+		return "", err
+	}
+	return trf, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// streamSelection is the set of input streams a job keeps, by input stream
+// index. video is -1 when there is no video to keep.
+type streamSelection struct {
+	video int
+	audio []int
+	subs  []int
+}
+
+// isCover reports whether st is an embedded cover picture rather than video.
+func (st probeStream) isCover() bool {
+	return st.CodecType == "video" && st.Disposition["attached_pic"] == 1
+}
+
+func (st probeStream) language() string {
+	if lang := st.Tags["language"]; lang != "" && lang != "und" {
+		return lang
+	}
+	return ""
+}
+
+// label describes a stream for the wizard list, e.g.
+// "#2 audio ac3 ger 6ch "Commentary"".
+func (st probeStream) label() string {
+	parts := []string{fmt.Sprintf("#%d", st.Index), st.CodecType, st.CodecName}
+	if lang := st.language(); lang != "" {
+		parts = append(parts, lang)
+	}
+	switch {
+	case st.CodecType == "video" && st.Width > 0:
+		parts = append(parts, fmt.Sprintf("%dx%d", st.Width, st.Height))
+	case st.Channels > 0:
+		parts = append(parts, fmt.Sprintf("%dch", st.Channels))
+	}
+	if title := st.Tags["title"]; title != "" {
+		parts = append(parts, strconv.Quote(title))
+	}
+	if st.Disposition["default"] == 1 {
+		parts = append(parts, "(default)")
+	}
+	return strings.Join(parts, " ")
+}
+
+// selectableStreams are the streams offered in the wizard, in input order.
+// Subtitles are only offered when the output can carry them.
+func selectableStreams(info *FFProbeOutput, subs bool) []probeStream {
+	var out []probeStream
+	for _, st := range info.Streams {
+		if (st.CodecType == "video" && !st.isCover()) || st.CodecType == "audio" || (subs && st.CodecType == "subtitle") {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// needsStreamChoice reports whether the input has more than one video or
+// audio stream to pick from, or subtitles that could be kept.
+func needsStreamChoice(info *FFProbeOutput, subs bool) bool {
+	count := map[string]int{}
+	for _, st := range selectableStreams(info, subs) {
+		count[st.CodecType]++
+	}
+	return count["video"] > 1 || count["audio"] > 1 || count["subtitle"] > 0
+}
+
+// defaultStreams picks the streams to keep. indices (from -streams) name
+// them exactly; langs and subLangs (from -alang and -slang) pick audio and
+// subtitle tracks by language. Otherwise it takes the first video and the
+// default audio track, like FFmpeg's own mapping would, and no subtitles.
+func defaultStreams(info *FFProbeOutput, indices []int, langs, subLangs []string) streamSelection {
+	sel := streamSelection{video: -1}
+	defaultAudio, hasDefault := -1, false
+	for _, st := range selectableStreams(info, true) {
+		named := slices.Contains(indices, st.Index)
+		switch st.CodecType {
+		case "video":
+			if sel.video < 0 || (named && !slices.Contains(indices, sel.video)) {
+				sel.video = st.Index
+			}
+		case "audio":
+			if named || (len(indices) == 0 && slices.Contains(langs, st.language())) {
+				sel.audio = append(sel.audio, st.Index)
+			}
+			if defaultAudio < 0 || (!hasDefault && st.Disposition["default"] == 1) {
+				defaultAudio = st.Index
+				hasDefault = st.Disposition["default"] == 1
+			}
+		case "subtitle":
+			byLang := slices.Contains(subLangs, "all") || slices.Contains(subLangs, st.language())
+			if named || (len(indices) == 0 && byLang) {
+				sel.subs = append(sel.subs, st.Index)
+			}
+		}
+	}
+
+	if len(indices) == 0 && len(sel.audio) == 0 && defaultAudio >= 0 {
+		sel.audio = []int{defaultAudio}
+	}
+	return sel
+}
+
+// stream returns the input stream with the given index, or nil.
+func (info *FFProbeOutput) stream(index int) *probeStream {
+	for i := range info.Streams {
+		if info.Streams[i].Index == index {
+			return &info.Streams[i]
+		}
+	}
+	return nil
+}
+
+// mapArgs returns explicit -map arguments for the video and, if withAudio,
+// the audio streams.
+func (sel streamSelection) mapArgs(withAudio bool) []string {
+	var args []string
+	if sel.video >= 0 {
+		args = append(args, "-map", fmt.Sprintf("0:%d", sel.video))
+	}
+	if withAudio {
+		for _, idx := range sel.audio {
+			args = append(args, "-map", fmt.Sprintf("0:%d", idx))
+		}
+	}
+	return args
+}
+
+func (sel streamSelection) has(index int) bool {
+	return sel.video == index || slices.Contains(sel.audio, index) || slices.Contains(sel.subs, index)
+}
+
+// toggle flips st in the selection. There is only ever one video stream, so
+// picking one replaces the other; single makes audio work the same way.
+func (sel streamSelection) toggle(st probeStream, single bool) streamSelection {
+	switch {
+	case st.CodecType == "video":
+		sel.video = st.Index
+	case st.CodecType == "subtitle" && sel.has(st.Index):
+		sel.subs = slices.DeleteFunc(slices.Clone(sel.subs), func(i int) bool { return i == st.Index })
+	case st.CodecType == "subtitle":
+		sel.subs = append(slices.Clone(sel.subs), st.Index)
+		slices.Sort(sel.subs)
+	case sel.has(st.Index):
+		sel.audio = slices.DeleteFunc(slices.Clone(sel.audio), func(i int) bool { return i == st.Index })
+	case single:
+		sel.audio = []int{st.Index}
+	default:
+		sel.audio = append(slices.Clone(sel.audio), st.Index)
+		slices.Sort(sel.audio)
+	}
+	return sel
+}
+
+// parseIndexList parses "0,2,3".
+func parseIndexList(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid stream index %q in -streams: use input stream numbers, e.g. 0,2,3", part)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
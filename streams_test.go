@@ -0,0 +1,26 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseIndexList(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{"0,2,3", []int{0, 2, 3}, false},
+		{" 1 , 4", []int{1, 4}, false},
+		{"0,x,2", nil, true},
+		{"-1", nil, true},
+		{"0,,1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseIndexList(tt.in)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("parseIndexList(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// textSubtitles are subtitle codecs FFmpeg can convert between. Anything
+// else (PGS, VobSub, DVB) is a stream of images.
+var textSubtitles = []string{"subrip", "srt", "ass", "ssa", "webvtt", "mov_text", "text"}
+
+// subtitleCodec returns the encoder for carrying a codec subtitle stream into
+// c, or "copy" if it can go in as-is.
+func (c containerInfo) subtitleCodec(codec string) (string, error) {
+	text := slices.Contains(textSubtitles, codec)
+	switch {
+	case c.Subtitle == "" && codec == "mov_text":
+		return "srt", nil // Matroska has no mov_text mapping
+	case c.Subtitle == "", codec == c.Subtitle:
+		return "copy", nil
+	case text:
+		return c.Subtitle, nil
+	}
+	return "", fmt.Errorf("%s subtitles are images and can't be converted for %s; use -container mkv or leave the track out", codec, strings.ToUpper(c.Name))
+}
+
+// subtitleArgs maps the selected subtitle streams and converts them for ctr,
+// keeping their language and default flag.
+func subtitleArgs(info *FFProbeOutput, sel streamSelection, ctr containerInfo) ([]string, error) {
+	var args []string
+	for i, idx := range sel.subs {
+		st := info.stream(idx)
+		if st == nil {
+			return nil, fmt.Errorf("subtitle stream %d not found", idx)
+		}
+		codec, err := ctr.subtitleCodec(st.CodecName)
+		if err != nil {
+			return nil, fmt.Errorf("stream #%d: %w", idx, err)
+		}
+
+		spec := fmt.Sprintf("s:%d", i)
+		args = append(args, "-map", fmt.Sprintf("0:%d", idx), "-c:"+spec, codec)
+		if lang := st.language(); lang != "" {
+			args = append(args, "-metadata:s:"+spec, "language="+lang)
+		}
+		disposition := "0"
+		if st.Disposition["default"] == 1 {
+			disposition = "default"
+		}
+		args = append(args, "-disposition:"+spec, disposition)
+	}
+	return args, nil
+}
+
+// burnIn is a subtitle track rendered into the picture: an input subtitle
+// stream, or an external file when file is set.
+type burnIn struct {
+	stream int
+	file   string
+}
+
+// burnSubtitleExts are the external subtitle files -burn accepts.
+var burnSubtitleExts = []string{".srt", ".ass", ".ssa", ".vtt"}
+
+// parseBurn reads -burn: an input stream index or a subtitle file.
+func parseBurn(s string) *burnIn {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return &burnIn{stream: n}
+	}
+	return &burnIn{stream: -1, file: s}
+}
+
+// label describes the burned track for the header and review screen.
+func (b *burnIn) label() string {
+	if b.file != "" {
+		return filepath.Base(b.file)
+	}
+	return fmt.Sprintf("#%d", b.stream)
+}
+
+// filters returns the subtitles filter for b. FFmpeg resets timestamps to 0
+// after an input -ss, and cuts and speed changes move them, so the frames are
+// put back onto the source timeline while the subtitles are drawn, then put
+// back.
+func (b *burnIn) filters(inputFile string, info *FFProbeOutput, tm timeMap) ([]videoFilter, error) {
+	var sub videoFilter
+	if b.file != "" {
+		if !slices.Contains(burnSubtitleExts, strings.ToLower(filepath.Ext(b.file))) {
+			return nil, fmt.Errorf("can't burn in %s: use a .srt, .ass or .vtt file", filepath.Base(b.file))
+		}
+		if !fileExists(b.file) {
+			return nil, fmt.Errorf("subtitle file not found: %s", b.file)
+		}
+		sub = newFilter(stageOverlay, "subtitles", kv("filename", filterPath(b.file)))
+	} else {
+		st := info.stream(b.stream)
+		if st == nil || st.CodecType != "subtitle" {
+			return nil, fmt.Errorf("stream #%d is not a subtitle track", b.stream)
+		}
+		if !slices.Contains(textSubtitles, st.CodecName) {
+			return nil, fmt.Errorf("can't burn in stream #%d: %s subtitles are images", b.stream, st.CodecName)
+		}
+		// si counts subtitle streams only
+		si := 0
+		for _, other := range info.Streams {
+			if other.CodecType == "subtitle" && other.Index < b.stream {
+				si++
+			}
+		}
+		sub = newFilter(stageOverlay, "subtitles", kv("filename", filterPath(inputFile)), kv("si", strconv.Itoa(si)))
+	}
+
+	if tm.identity() {
+		return []videoFilter{sub}, nil
+	}
+	return []videoFilter{
+		newFilter(stageOverlay, "setpts", pos(tm.toSource())),
+		sub,
+		newFilter(stageOverlay, "setpts", pos(tm.toOutput())),
+	}, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDuration reads a time: "1:02:03.5" or "2:03.5", plain seconds
+// ("83.5"), or Go style units ("1m30s", "1h2m", "500ms").
+func parseDuration(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, fmt.Errorf("empty time")
+	}
+	if sec, err := strconv.ParseFloat(s, 64); err == nil {
+		if sec < 0 || math.IsInf(sec, 0) || math.IsNaN(sec) {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		return sec, nil
+	}
+	if !strings.Contains(s, ":") {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid time %q: use e.g. 1:30, 90, 1m30s or 01:02:03.5", s)
+		}
+		return d.Seconds(), nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q: use HH:MM:SS.ms", s)
+	}
+	sec := 0.0
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		// only the first field may exceed 59
+		if err != nil || v < 0 || (i > 0 && v >= 60) || (i < len(parts)-1 && v != math.Trunc(v)) {
+			return 0, fmt.Errorf("invalid time %q: use HH:MM:SS.ms", s)
+		}
+		sec = sec*60 + v
+	}
+	return sec, nil
+}
+
+// parseTimestamp reads an in or out point in a source of the given duration
+// and frame rate: any parseDuration time, a frame number ("1200f"), or either
+// of those negated to count back from the end ("-10", "-1:30"). Durations
+// and rates of 0 are unknown, which only rules out the forms that need them.
+func parseTimestamp(s string, duration, fps float64) (float64, error) {
+	s = strings.TrimSpace(s)
+	fromEnd := strings.HasPrefix(s, "-")
+	body := strings.TrimSpace(strings.TrimPrefix(s, "-"))
+
+	var sec float64
+	if frames, ok := strings.CutSuffix(strings.ToLower(body), "f"); ok {
+		n, err := strconv.Atoi(frames)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid frame number %q", s)
+		}
+		if fps <= 0 {
+			return 0, fmt.Errorf("the frame rate is unknown, can't use frame number %q", s)
+		}
+		sec = float64(n) / fps
+	} else {
+		var err error
+		if sec, err = parseDuration(body); err != nil {
+			return 0, err
+		}
+	}
+
+	if fromEnd {
+		if duration <= 0 {
+			return 0, fmt.Errorf("the duration is unknown, can't count %q from the end", s)
+		}
+		sec = duration - sec
+	}
+	if sec < 0 || (duration > 0 && sec > duration) {
+		return 0, fmt.Errorf("%s is outside the file, which lasts %s", s, formatDuration(duration))
+	}
+	return sec, nil
+}
+
+// splitRange splits "in-out" into its two points. The dash that separates
+// them follows a time directly or stands alone, so "-30 - -5" and "10 -5"
+// (end minus 5) both work; without a dash the points are separated by
+// spaces. An empty in or out point means the start or end of the file.
+func splitRange(s string) (string, string, bool) {
+	s = strings.TrimSpace(s)
+	for i := 1; i < len(s); i++ {
+		if s[i] != '-' {
+			continue
+		}
+		before, after := s[i-1], byte(' ')
+		if i+1 < len(s) {
+			after = s[i+1]
+		}
+		if (before != ' ' && before != '-') || (before == ' ' && after == ' ') {
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	if fields := strings.Fields(s); len(fields) == 2 {
+		return fields[0], fields[1], true
+	}
+	return "", "", false
+}
+
+// probeKeyframes lists the keyframe times of a video stream. Only the
+// keyframes are decoded, so it takes a fraction of the encode's time.
+func probeKeyframes(file string, video int, duration float64, ch chan<- progressMsg, logPath string) ([]float64, error) {
+	args := []string{"-y", "-skip_frame", "nokey", "-i", file, "-map", fmt.Sprintf("0:%d", video), "-an", "-sn",
+		"-vf", "showinfo", "-fps_mode", "passthrough", "-f", "null", "-"}
+	stderr, err := runFFmpegOutput(args, ch, duration, "Keyframe Scan", logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyframes []float64
+	for _, m := range showinfoTimeRe.FindAllStringSubmatch(stderr, -1) {
+		if t, err := strconv.ParseFloat(m[1], 64); err == nil {
+			keyframes = append(keyframes, t)
+		}
+	}
+	if len(keyframes) == 0 {
+		return nil, fmt.Errorf("no keyframes found")
+	}
+	slices.Sort(keyframes)
+	return keyframes, nil
+}
+
+// nearestKeyframe returns the keyframe closest to t.
+func nearestKeyframe(keyframes []float64, t float64) float64 {
+	i, _ := slices.BinarySearch(keyframes, t)
+	best := keyframes[min(i, len(keyframes)-1)]
+	if i > 0 && t-keyframes[i-1] < math.Abs(best-t) {
+		best = keyframes[i-1]
+	}
+	return best
+}
+
+// snapCopyBlocker says why a range snapped to keyframes can't be cut by
+// stream copy, or returns "" if it can. A copy keeps the source's codec,
+// picture and bitrate, so anything that changes those rules it out.
+func snapCopyBlocker(opts encodeOptions, info *FFProbeOutput, span float64) string {
+	switch {
+	case opts.needsEncode():
+		return "other settings need an encode"
+	case buildScaleFilter(opts.resInput) != nil || opts.fpsInput != "":
+		return "the resolution or frame rate changes"
+	case opts.targetMB > 0 && sourceBytes(opts, info, span) > opts.targetMB*1024*1024:
+		return fmt.Sprintf("a copy wouldn't fit in %g MB", opts.targetMB)
+	}
+	return ""
+}
+
+// snapRanges moves every in and out point to its nearest keyframe, so the
+// decoder never starts mid-GOP. Points at the very end of the file stay put,
+// as do ranges that would collapse or overlap once snapped.
+func snapRanges(ranges []keepRange, keyframes []float64, duration float64) []keepRange {
+	snapped := make([]keepRange, 0, len(ranges))
+	for _, r := range ranges {
+		s := keepRange{nearestKeyframe(keyframes, r.start), r.end}
+		if duration <= 0 || r.end < duration {
+			s.end = nearestKeyframe(keyframes, r.end)
+		}
+		if s.end <= s.start || (len(snapped) > 0 && s.start < snapped[len(snapped)-1].end) {
+			s = r
+		}
+		snapped = append(snapped, s)
+	}
+	return snapped
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"83.5", 83.5, false},
+		{" 90 ", 90, false},
+		{"2:03.5", 123.5, false},
+		{"1:02:03.5", 3723.5, false},
+		{"90:00", 5400, false}, // the first field may exceed 59
+		{"1m30s", 90, false},
+		{"1H2M", 3720, false},
+		{"500ms", 0.5, false},
+		{"", 0, true},
+		{"-5", 0, true},
+		{"1:60", 0, true},
+		{"1.5:30", 0, true},
+		{"1:2:3:4", 0, true},
+		{"soon", 0, true},
+		{"inf", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDuration(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseDuration(%q) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in            string
+		duration, fps float64
+		want          float64
+		wantErr       bool
+	}{
+		{"1:30", 600, 30, 90, false},
+		{"1200f", 600, 30, 40, false},
+		{"1200F", 600, 30, 40, false},
+		{"-10", 600, 30, 590, false},
+		{"-1:30", 600, 30, 510, false},
+		{"-300f", 600, 30, 590, false},
+		{"600", 600, 30, 600, false},
+		{"1:30", 0, 0, 90, false}, // unknown duration and rate are fine here
+		{"1200f", 600, 0, 0, true},
+		{"-10", 0, 30, 0, true},
+		{"601", 600, 30, 0, true},
+		{"-700", 600, 30, 0, true},
+		{"xf", 600, 30, 0, true},
+		{"later", 600, 30, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.in, tt.duration, tt.fps)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTimestamp(%q, %g, %g) error = %v, want error %v", tt.in, tt.duration, tt.fps, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseTimestamp(%q, %g, %g) = %g, want %g", tt.in, tt.duration, tt.fps, got, tt.want)
+		}
+	}
+}
+
+func TestSplitRange(t *testing.T) {
+	tests := []struct {
+		in       string
+		from, to string
+		ok       bool
+	}{
+		{"1:00-1:30", "1:00", "1:30", true},
+		{"90 - 1m45s", "90", "1m45s", true},
+		{"10 -5", "10", "-5", true},
+		{"-30 - -5", "-30", "-5", true},
+		{"-30--5", "-30", "-5", true},
+		{"1200f-2400f", "1200f", "2400f", true},
+		{"10-", "10", "", true},
+		{"-10", "", "", false},
+		{"10 20", "10", "20", true},
+		{"10", "", "", false},
+		{"1 2 3", "", "", false},
+	}
+	for _, tt := range tests {
+		from, to, ok := splitRange(tt.in)
+		if from != tt.from || to != tt.to || ok != tt.ok {
+			t.Errorf("splitRange(%q) = %q, %q, %v, want %q, %q, %v", tt.in, from, to, ok, tt.from, tt.to, tt.ok)
+		}
+	}
+}